@@ -1,14 +1,19 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
+	"strings"
+	"sync"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -17,6 +22,11 @@ import (
 
 type Client struct {
 	EthClient *ethclient.Client
+
+	mu sync.Mutex
+	// London support by chain ID
+	london map[string]bool
+	nonces *NonceManager
 }
 
 func (c *Client) ChainID() (*big.Int, error) {
@@ -56,46 +66,220 @@ func (c *Client) CreateCallMessage(
 	return msg, nil
 }
 
+// Nonces of the transactions made by the client are handed out by nm,
+// unless TransactionOptions has its own. Nil means the node's pending nonce.
+func (c *Client) SetNonceManager(nm *NonceManager) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nonces = nm
+}
+
+func (c *Client) NonceManager() *NonceManager {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.nonces
+}
+
+// Reports the result of sending tx to the client's nonce manager, if any.
+// SendTransaction does it already. Transactions sent some other way,
+// or handed out to be sent elsewhere, have to be reported here,
+// and so do ones that never got signed.
+func (c *Client) TransactionDone(sender common.Address, tx *types.Transaction, sendErr error) error {
+	if nm := c.NonceManager(); nm != nil {
+		return nm.Done(sender, tx.Nonce(), sendErr)
+	}
+
+	return nil
+}
+
+// Whether the chain has EIP-1559 fees, i.e. the latest header has a base fee.
+// Checked once per chain.
+func (c *Client) SupportsLondon() (bool, error) {
+	chainId, err := c.EthClient.ChainID(context.Background())
+	if err != nil {
+		return false, &RPCError{Kind: ChainIDFailed, Err: err}
+	}
+
+	return c.supportsLondon(chainId)
+}
+
 func (c *Client) CreateTransaction(msg ethereum.CallMsg, gasMultiplier float64) (*types.Transaction, error) {
-	// TODO: Handle client errors while constructing a transaction
+	return c.CreateTransactionWithOptions(msg, TransactionOptions{GasMultiplier: gasMultiplier})
+}
+
+// Failures are returned as *RPCError, a reverting call as ExecutionReverted
+// with the decoded revert data if the node returned it.
+func (c *Client) CreateTransactionWithOptions(msg ethereum.CallMsg, opts TransactionOptions) (*types.Transaction, error) {
+	chainId, err := c.EthClient.ChainID(context.Background())
+	if err != nil {
+		return nil, &RPCError{Kind: ChainIDFailed, Err: err}
+	}
 
-	chainId, _ := c.EthClient.ChainID(context.Background())
-	nonce, _ := c.EthClient.PendingNonceAt(context.Background(), msg.From)
-	gasPrice, _ := c.EthClient.SuggestGasPrice(context.Background())
-	gasTip, _ := c.gasTip(new(big.Float).SetFloat64(gasMultiplier))
-	gasLimit, _ := c.EthClient.EstimateGas(context.Background(), msg)
-	toAddress := msg.To
-	value := msg.Value
+	txType := opts.Type
+	if txType == TransactionTypeAuto {
+		london, err := c.supportsLondon(chainId)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case london:
+			txType = TransactionTypeDynamicFee
+		case len(msg.AccessList) > 0:
+			txType = TransactionTypeAccessList
+		default:
+			txType = TransactionTypeLegacy
+		}
+	}
 
+	gasLimit := opts.Gas
 	if gasLimit == 0 {
-		return nil, GasEstimateFailed
+		if gasLimit, err = c.EthClient.EstimateGas(context.Background(), msg); err != nil {
+			return nil, estimateGasError(err)
+		}
+		if gasLimit == 0 {
+			return nil, GasEstimateFailed
+		}
 	}
 
-	gasCost := new(big.Int).Mul(gasPrice, big.NewInt(int64(gasLimit)))
+	fees, err := c.fees(txType, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Taken last, so that a failure above doesn't leave a gap
+	nonce, err := c.nonce(msg.From, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	gasCost := new(big.Int).Mul(fees.GasFeeCap, big.NewInt(int64(gasLimit)))
 
 	fmt.Println("Gas cost estimate:", gasCost, "wei")
 	fmt.Println("Gas limit:", gasLimit)
-	fmt.Println("Gas price:", gasPrice, "wei")
-	fmt.Println("Gas tip (after multiplier):", gasTip, "wei")
+
+	switch txType {
+	case TransactionTypeLegacy:
+		fmt.Println("Gas price:", fees.GasFeeCap, "wei")
+		fmt.Println()
+
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: fees.GasFeeCap,
+			Gas:      gasLimit,
+			To:       msg.To,
+			Value:    msg.Value,
+			Data:     msg.Data,
+		}), nil
+	case TransactionTypeAccessList:
+		fmt.Println("Gas price:", fees.GasFeeCap, "wei")
+		fmt.Println()
+
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    chainId,
+			Nonce:      nonce,
+			GasPrice:   fees.GasFeeCap,
+			Gas:        gasLimit,
+			To:         msg.To,
+			Value:      msg.Value,
+			Data:       msg.Data,
+			AccessList: msg.AccessList,
+		}), nil
+	}
+
+	fmt.Println("Max fee per gas:", fees.GasFeeCap, "wei")
+	fmt.Println("Gas tip:", fees.GasTipCap, "wei")
 	fmt.Println()
 
 	txData := &types.DynamicFeeTx{
-		ChainID:   chainId,
-		Nonce:     nonce,
-		GasTipCap: gasTip,
-		GasFeeCap: gasPrice,
-		Gas:       gasLimit,
-		To:        toAddress,
-		Value:     value,
-		Data:      msg.Data,
+		ChainID:    chainId,
+		Nonce:      nonce,
+		GasTipCap:  fees.GasTipCap,
+		GasFeeCap:  fees.GasFeeCap,
+		Gas:        gasLimit,
+		To:         msg.To,
+		Value:      msg.Value,
+		Data:       msg.Data,
+		AccessList: msg.AccessList,
 	}
 
 	tx := types.NewTx(txData)
 	return tx, nil
 }
 
+func (c *Client) nonce(sender common.Address, opts TransactionOptions) (uint64, error) {
+	switch {
+	case opts.Nonce != nil:
+		return *opts.Nonce, nil
+	case opts.Nonces != nil:
+		return opts.Nonces.Next(sender)
+	}
+
+	if nm := c.NonceManager(); nm != nil {
+		return nm.Next(sender)
+	}
+
+	nonce, err := c.EthClient.PendingNonceAt(context.Background(), sender)
+	if err != nil {
+		return 0, &RPCError{Kind: NonceFailed, Err: err}
+	}
+
+	return nonce, nil
+}
+
+// Without a FeeStrategy, the suggested gas price is the fee cap and
+// the multiplier is applied to the suggested tip, or to the gas price
+// of legacy and access list transactions
+func (c *Client) fees(txType TransactionType, opts TransactionOptions) (*Fees, error) {
+	if opts.FeeStrategy != nil {
+		fees, err := opts.FeeStrategy.Fees(c)
+		if err != nil || txType == TransactionTypeDynamicFee {
+			return fees, err
+		}
+
+		gasPrice := fees.gasPrice()
+		return &Fees{GasFeeCap: gasPrice, GasTipCap: gasPrice, BaseFee: fees.BaseFee}, nil
+	}
+
+	multiplier := big.NewFloat(1)
+	if opts.GasMultiplier != 0 {
+		multiplier.SetFloat64(opts.GasMultiplier)
+	}
+
+	gasPrice, err := c.EthClient.SuggestGasPrice(context.Background())
+	if err != nil {
+		return nil, &RPCError{Kind: GasPriceFailed, Err: err}
+	}
+
+	fmt.Println("Gas price:", gasPrice, "wei")
+
+	if txType != TransactionTypeDynamicFee {
+		gasPrice, _ = new(big.Float).Mul(new(big.Float).SetInt(gasPrice), multiplier).Int(nil)
+		return &Fees{GasFeeCap: gasPrice, GasTipCap: gasPrice}, nil
+	}
+
+	gasTip, err := c.gasTip(multiplier)
+	if err != nil {
+		return nil, &RPCError{Kind: GasTipFailed, Err: err}
+	}
+	if gasPrice.Cmp(gasTip) < 0 {
+		gasPrice = gasTip
+	}
+
+	return &Fees{GasFeeCap: gasPrice, GasTipCap: gasTip}, nil
+}
+
 func (c *Client) SendTransaction(tx *types.Transaction) (*string, error) {
 	err := c.EthClient.SendTransaction(context.Background(), tx)
+
+	if sender, senderErr := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); senderErr == nil {
+		if doneErr := c.TransactionDone(sender, tx, err); doneErr != nil {
+			fmt.Println(doneErr)
+		}
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -168,6 +352,31 @@ func (c *Client) ReadLogs(
 	return logFields, nil
 }
 
+func (c *Client) supportsLondon(chainId *big.Int) (bool, error) {
+	c.mu.Lock()
+	london, ok := c.london[chainId.String()]
+	c.mu.Unlock()
+
+	if ok {
+		return london, nil
+	}
+
+	header, err := c.EthClient.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return false, &RPCError{Kind: HeaderFailed, Err: err}
+	}
+	london = header.BaseFee != nil
+
+	c.mu.Lock()
+	if c.london == nil {
+		c.london = make(map[string]bool)
+	}
+	c.london[chainId.String()] = london
+	c.mu.Unlock()
+
+	return london, nil
+}
+
 func (c *Client) gasTip(multiplier *big.Float) (*big.Int, error) {
 	gasTip, err := c.EthClient.SuggestGasTipCap(context.Background())
 	if err != nil {
@@ -183,3 +392,50 @@ func (c *Client) gasTip(multiplier *big.Float) (*big.Int, error) {
 
 	return gasTip, nil
 }
+
+var (
+	revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	panicSelector  = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// Tells a reverting call apart from the node failing to estimate
+func estimateGasError(err error) error {
+	revert, ok := decodeRevert(err)
+	if !ok {
+		return &RPCError{Kind: GasEstimateFailed, Err: err}
+	}
+
+	return &RPCError{Kind: ExecutionReverted, Err: err, Revert: revert}
+}
+
+// Geth-compatible nodes return the revert data as the hex error data,
+// reverts without data only have the message
+func decodeRevert(err error) (*Revert, bool) {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if hexData, ok := dataErr.ErrorData().(string); ok {
+			if data, decodeErr := hexutil.Decode(hexData); decodeErr == nil {
+				return newRevert(data), true
+			}
+		}
+	}
+
+	if strings.Contains(err.Error(), "execution reverted") {
+		return &Revert{}, true
+	}
+
+	return nil, false
+}
+
+func newRevert(data []byte) *Revert {
+	revert := &Revert{Data: data}
+
+	switch {
+	case len(data) >= 4 && bytes.Equal(data[:4], revertSelector):
+		revert.Reason, _ = abi.UnpackRevert(data)
+	case len(data) == 4+32 && bytes.Equal(data[:4], panicSelector):
+		revert.PanicCode = new(big.Int).SetBytes(data[4:])
+	}
+
+	return revert
+}
@@ -0,0 +1,212 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Fees of a transaction. Legacy and access list transactions pay their
+// gas price in full, so they use BaseFee + GasTipCap as the gas price,
+// or GasFeeCap if it's lower or the base fee is unknown.
+type Fees struct {
+	GasFeeCap *big.Int
+	GasTipCap *big.Int
+
+	// Base fee the fee cap was projected from, nil if unknown
+	BaseFee *big.Int
+}
+
+// Decides the fees of a transaction, see TransactionOptions
+type FeeStrategy interface {
+	Fees(c *Client) (*Fees, error)
+}
+
+const (
+	DefaultFeeHistoryBlocks = 20
+
+	// The base fee grows by at most 12.5% per block,
+	// 6 blocks ahead is about twice the current one
+	DefaultProjectedBlocks = 6
+)
+
+var (
+	FeeStrategySlow     = &FeeHistoryStrategy{Percentile: 10}
+	FeeStrategyStandard = &FeeHistoryStrategy{Percentile: 50}
+	FeeStrategyFast     = &FeeHistoryStrategy{Percentile: 90}
+)
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								FEE HISTORY
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+// Tip is the median of the given reward percentile over the last blocks,
+// as returned by eth_feeHistory. The fee cap covers the base fee rising
+// at the maximum rate for ProjectedBlocks blocks, plus the tip.
+type FeeHistoryStrategy struct {
+	// 0 to 100
+	Percentile float64
+
+	// Zero means DefaultFeeHistoryBlocks
+	Blocks uint64
+
+	// Zero means DefaultProjectedBlocks
+	ProjectedBlocks int
+}
+
+func (s *FeeHistoryStrategy) Fees(c *Client) (*Fees, error) {
+	if s.Percentile < 0 || s.Percentile > 100 {
+		return nil, fmt.Errorf("%w: percentile %v out of range", InvalidFeeStrategy, s.Percentile)
+	}
+
+	blocks := s.Blocks
+	if blocks == 0 {
+		blocks = DefaultFeeHistoryBlocks
+	}
+
+	projectedBlocks := s.ProjectedBlocks
+	if projectedBlocks == 0 {
+		projectedBlocks = DefaultProjectedBlocks
+	}
+
+	history, err := c.EthClient.FeeHistory(context.Background(), blocks, nil, []float64{s.Percentile})
+	if err != nil {
+		return nil, &RPCError{Kind: FeeHistoryFailed, Err: err}
+	}
+	if len(history.BaseFee) == 0 {
+		return nil, FeeHistoryFailed
+	}
+
+	// Empty blocks have zero rewards and would drag the tip down
+	var rewards []*big.Int
+	for i, blockRewards := range history.Reward {
+		if i < len(history.GasUsedRatio) && history.GasUsedRatio[i] > 0 && len(blockRewards) > 0 {
+			rewards = append(rewards, blockRewards[0])
+		}
+	}
+
+	var gasTip *big.Int
+	if len(rewards) > 0 {
+		sort.Slice(rewards, func(i, j int) bool { return rewards[i].Cmp(rewards[j]) < 0 })
+		gasTip = new(big.Int).Set(rewards[len(rewards)/2])
+	} else {
+		if gasTip, err = c.EthClient.SuggestGasTipCap(context.Background()); err != nil {
+			return nil, &RPCError{Kind: GasTipFailed, Err: err}
+		}
+	}
+
+	// The last base fee is the one of the next block
+	baseFee := history.BaseFee[len(history.BaseFee)-1]
+	projectedBaseFee := projectBaseFee(baseFee, projectedBlocks-1)
+
+	return &Fees{
+		GasFeeCap: new(big.Int).Add(projectedBaseFee, gasTip),
+		GasTipCap: gasTip,
+		BaseFee:   baseFee,
+	}, nil
+}
+
+// Base fee after blocks full blocks
+func projectBaseFee(baseFee *big.Int, blocks int) *big.Int {
+	projected := new(big.Int).Set(baseFee)
+
+	for i := 0; i < blocks; i++ {
+		projected.Add(projected, new(big.Int).Div(projected, big.NewInt(8)))
+	}
+
+	return projected
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								FIXED
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+// Always the same fees. A missing tip, or one above the fee cap,
+// is the fee cap.
+type FixedFeeStrategy struct {
+	GasFeeCap *big.Int
+	GasTipCap *big.Int
+}
+
+func (s *FixedFeeStrategy) Fees(c *Client) (*Fees, error) {
+	if s.GasFeeCap == nil {
+		return nil, fmt.Errorf("%w: no fee cap", InvalidFeeStrategy)
+	}
+
+	gasTip := s.GasTipCap
+	if gasTip == nil || gasTip.Cmp(s.GasFeeCap) > 0 {
+		gasTip = s.GasFeeCap
+	}
+
+	return &Fees{
+		GasFeeCap: new(big.Int).Set(s.GasFeeCap),
+		GasTipCap: new(big.Int).Set(gasTip),
+	}, nil
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								CEILING
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+// Lowers the fees of another strategy to at most MaxFeeGwei. Returns
+// FeeCeilingExceeded if the base fee alone is already above it, since such
+// a transaction would be stuck.
+type FeeCeilingStrategy struct {
+	Strategy   FeeStrategy
+	MaxFeeGwei float64
+}
+
+func (s *FeeCeilingStrategy) Fees(c *Client) (*Fees, error) {
+	if s.Strategy == nil {
+		return nil, fmt.Errorf("%w: no strategy to cap", InvalidFeeStrategy)
+	}
+
+	// Also rejects NaN, which GweiToWei can't convert
+	if !(s.MaxFeeGwei > 0) || math.IsInf(s.MaxFeeGwei, 1) {
+		return nil, fmt.Errorf("%w: fee ceiling of %v gwei", InvalidFeeStrategy, s.MaxFeeGwei)
+	}
+	ceiling := GweiToWei(s.MaxFeeGwei)
+	if ceiling.Sign() == 0 {
+		return nil, fmt.Errorf("%w: fee ceiling of %v gwei", InvalidFeeStrategy, s.MaxFeeGwei)
+	}
+
+	fees, err := s.Strategy.Fees(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if fees.BaseFee != nil && fees.BaseFee.Cmp(ceiling) > 0 {
+		return nil, FeeCeilingExceeded
+	}
+	if fees.GasFeeCap.Cmp(ceiling) > 0 {
+		fees.GasFeeCap = ceiling
+	}
+	if fees.GasTipCap.Cmp(fees.GasFeeCap) > 0 {
+		fees.GasTipCap = new(big.Int).Set(fees.GasFeeCap)
+	}
+
+	return fees, nil
+}
+
+// Gas price of a legacy or access list transaction
+func (f *Fees) gasPrice() *big.Int {
+	if f.BaseFee == nil {
+		return f.GasFeeCap
+	}
+
+	gasPrice := new(big.Int).Add(f.BaseFee, f.GasTipCap)
+	if gasPrice.Cmp(f.GasFeeCap) > 0 {
+		return f.GasFeeCap
+	}
+
+	return gasPrice
+}
+
+func GweiToWei(gwei float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(params.GWei)).Int(nil)
+	return wei
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestFixedFeeStrategy(t *testing.T) {
+	fees, err := (&FixedFeeStrategy{GasFeeCap: big.NewInt(100), GasTipCap: big.NewInt(200)}).Fees(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The tip can't be above the cap
+	if fees.GasFeeCap.Int64() != 100 || fees.GasTipCap.Int64() != 100 {
+		t.Fatalf("fees = %v, %v", fees.GasFeeCap, fees.GasTipCap)
+	}
+
+	if _, err := (&FixedFeeStrategy{}).Fees(nil); !errors.Is(err, InvalidFeeStrategy) {
+		t.Fatalf("err = %v, want InvalidFeeStrategy", err)
+	}
+}
+
+func TestFeeCeilingStrategy(t *testing.T) {
+	fixed := &FixedFeeStrategy{GasFeeCap: GweiToWei(50), GasTipCap: GweiToWei(30)}
+
+	fees, err := (&FeeCeilingStrategy{Strategy: fixed, MaxFeeGwei: 20}).Fees(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.GasFeeCap.Cmp(GweiToWei(20)) != 0 || fees.GasTipCap.Cmp(GweiToWei(20)) != 0 {
+		t.Fatalf("fees = %v, %v", fees.GasFeeCap, fees.GasTipCap)
+	}
+
+	fees, err = (&FeeCeilingStrategy{Strategy: fixed, MaxFeeGwei: 100}).Fees(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fees.GasFeeCap.Cmp(GweiToWei(50)) != 0 || fees.GasTipCap.Cmp(GweiToWei(30)) != 0 {
+		t.Fatalf("fees = %v, %v", fees.GasFeeCap, fees.GasTipCap)
+	}
+
+	invalid := []*FeeCeilingStrategy{
+		{MaxFeeGwei: 20},
+		{Strategy: fixed},
+		{Strategy: fixed, MaxFeeGwei: -1},
+		{Strategy: fixed, MaxFeeGwei: 1e-12},
+		{Strategy: fixed, MaxFeeGwei: math.NaN()},
+		{Strategy: fixed, MaxFeeGwei: math.Inf(1)},
+	}
+	for _, strategy := range invalid {
+		if _, err := strategy.Fees(nil); !errors.Is(err, InvalidFeeStrategy) {
+			t.Fatalf("%+v: err = %v, want InvalidFeeStrategy", strategy, err)
+		}
+	}
+
+	// A base fee above the ceiling would leave the transaction stuck
+	withBaseFee := feeStrategyFunc(func(c *Client) (*Fees, error) {
+		return &Fees{GasFeeCap: GweiToWei(50), GasTipCap: GweiToWei(1), BaseFee: GweiToWei(30)}, nil
+	})
+	if _, err := (&FeeCeilingStrategy{Strategy: withBaseFee, MaxFeeGwei: 20}).Fees(nil); err != FeeCeilingExceeded {
+		t.Fatalf("err = %v, want FeeCeilingExceeded", err)
+	}
+}
+
+type feeStrategyFunc func(c *Client) (*Fees, error)
+
+func (f feeStrategyFunc) Fees(c *Client) (*Fees, error) {
+	return f(c)
+}
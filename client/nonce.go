@@ -0,0 +1,395 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Hands out nonces per sender locally, so that transactions made concurrently
+// don't get the same one. Every nonce handed out by Next is in flight until
+// Done or Release is called with it. Nonces of transactions that weren't
+// sent are reused. The state is kept in a JSON file if a path is given.
+type NonceManager struct {
+	client *Client
+	path   string
+
+	mu      sync.Mutex
+	senders map[common.Address]*senderNonces
+}
+
+type senderNonces struct {
+	Next     uint64   `json:"next"`
+	Released []uint64 `json:"released,omitempty"`
+
+	// Checked against the node once per run
+	synced bool
+
+	// Handed out and not done yet. Not persisted, since after a restart
+	// they're either sent or free again.
+	inFlight map[uint64]bool
+}
+
+func NewNonceManager(client *Client, path string) (*NonceManager, error) {
+	nm := &NonceManager{
+		client:  client,
+		path:    path,
+		senders: make(map[common.Address]*senderNonces),
+	}
+
+	if path == "" {
+		return nm, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nm, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &nm.senders); err != nil {
+		return nil, err
+	}
+
+	return nm, nil
+}
+
+// Lowest released nonce, or the next new one
+func (nm *NonceManager) Next(sender common.Address) (uint64, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	state, err := nm.sender(sender)
+	if err != nil {
+		return 0, err
+	}
+
+	next, released := state.Next, state.Released
+
+	var nonce uint64
+	if len(state.Released) > 0 {
+		nonce = state.Released[0]
+		state.Released = state.Released[1:]
+	} else {
+		nonce = state.Next
+		state.Next++
+	}
+
+	// Otherwise the nonce would be lost for good
+	if err := nm.save(); err != nil {
+		state.Next, state.Released = next, released
+		return 0, err
+	}
+	state.inFlight[nonce] = true
+
+	return nonce, nil
+}
+
+// Gives back a nonce whose transaction wasn't sent. Nonces that aren't
+// in flight are ignored, e.g. one of a replacement transaction, since
+// they may well be used already.
+func (nm *NonceManager) Release(sender common.Address, nonce uint64) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	state, err := nm.sender(sender)
+	if err != nil {
+		return err
+	}
+
+	if !state.inFlight[nonce] {
+		return nil
+	}
+
+	delete(state.inFlight, nonce)
+	state.release(nonce)
+
+	return nm.save()
+}
+
+// To be called with the result of sending the transaction. Resyncs with
+// the node if the nonce was already used, e.g. by another wallet,
+// and releases the nonce on other errors. Like Release, ignores nonces
+// that aren't in flight.
+func (nm *NonceManager) Done(sender common.Address, nonce uint64, sendErr error) error {
+	switch {
+	case sendErr == nil, isAlreadyKnown(sendErr):
+		_, err := nm.sent(sender, nonce)
+		return err
+	case isNonceTooLow(sendErr):
+		inFlight, err := nm.sent(sender, nonce)
+		if err != nil || !inFlight {
+			return err
+		}
+		return nm.Resync(sender)
+	}
+
+	return nm.Release(sender, nonce)
+}
+
+// Reports whether the nonce was in flight
+func (nm *NonceManager) sent(sender common.Address, nonce uint64) (bool, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	state, err := nm.sender(sender)
+	if err != nil {
+		return false, err
+	}
+
+	inFlight := state.inFlight[nonce]
+	delete(state.inFlight, nonce)
+
+	return inFlight, nil
+}
+
+// Moves past the nonces the node already knows of
+func (nm *NonceManager) Resync(sender common.Address) error {
+	pendingNonce, err := nm.client.EthClient.PendingNonceAt(context.Background(), sender)
+	if err != nil {
+		return &RPCError{Kind: NonceFailed, Err: err}
+	}
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	state, err := nm.sender(sender)
+	if err != nil {
+		return err
+	}
+
+	state.sync(pendingNonce)
+
+	return nm.save()
+}
+
+// Nonces that keep later transactions from being mined: released ones,
+// and the node's pending nonce if it's behind, i.e. its transaction
+// was dropped or never arrived. Nonces in flight aren't gaps, their
+// transactions may still be on the way.
+func (nm *NonceManager) Gaps(sender common.Address) ([]uint64, error) {
+	pendingNonce, err := nm.client.EthClient.PendingNonceAt(context.Background(), sender)
+	if err != nil {
+		return nil, &RPCError{Kind: NonceFailed, Err: err}
+	}
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	state, err := nm.sender(sender)
+	if err != nil {
+		return nil, err
+	}
+
+	state.sync(pendingNonce)
+
+	gaps := append([]uint64(nil), state.Released...)
+	if pendingNonce < state.Next && !state.isReleased(pendingNonce) && !state.inFlight[pendingNonce] {
+		gaps = append(gaps, pendingNonce)
+		sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	}
+
+	return gaps, nm.save()
+}
+
+// Sends a zero value transfer to the sender itself for every gap.
+// Returns the hashes of the sent transactions.
+func (nm *NonceManager) FillGaps(
+	sender common.Address,
+	sign func(tx *types.Transaction) (*types.Transaction, error),
+) ([]common.Hash, error) {
+	gaps, err := nm.Gaps(sender)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []common.Hash
+
+	for _, nonce := range gaps {
+		if err := nm.claim(sender, nonce); err != nil {
+			return hashes, err
+		}
+
+		hash, err := nm.fillGap(sender, nonce, sign)
+		if doneErr := nm.Done(sender, nonce, err); doneErr != nil {
+			return hashes, doneErr
+		}
+		if err != nil && !isAlreadyKnown(err) {
+			return hashes, err
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, nil
+}
+
+func (nm *NonceManager) fillGap(
+	sender common.Address,
+	nonce uint64,
+	sign func(tx *types.Transaction) (*types.Transaction, error),
+) (common.Hash, error) {
+	msg := ethereum.CallMsg{From: sender, To: &sender, Value: new(big.Int)}
+
+	tx, err := nm.client.CreateTransactionWithOptions(msg, TransactionOptions{Nonce: &nonce})
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	signedTx, err := sign(tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+
+	return signedTx.Hash(), nm.client.EthClient.SendTransaction(context.Background(), signedTx)
+}
+
+// Takes a specific nonce, e.g. a gap, so that Next doesn't hand it out.
+// Fails if it's in flight already.
+func (nm *NonceManager) claim(sender common.Address, nonce uint64) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	state, err := nm.sender(sender)
+	if err != nil {
+		return err
+	}
+
+	if state.inFlight[nonce] {
+		return fmt.Errorf("nonce %d is in flight", nonce)
+	}
+
+	released := state.Released
+	state.Released = make([]uint64, 0, len(released))
+	for _, releasedNonce := range released {
+		if releasedNonce != nonce {
+			state.Released = append(state.Released, releasedNonce)
+		}
+	}
+
+	if err := nm.save(); err != nil {
+		state.Released = released
+		return err
+	}
+	state.inFlight[nonce] = true
+
+	return nil
+}
+
+// Must be called with the lock held
+func (nm *NonceManager) sender(sender common.Address) (*senderNonces, error) {
+	state, ok := nm.senders[sender]
+	if !ok {
+		state = new(senderNonces)
+		nm.senders[sender] = state
+	}
+	if state.inFlight == nil {
+		state.inFlight = make(map[uint64]bool)
+	}
+
+	if !state.synced {
+		pendingNonce, err := nm.client.EthClient.PendingNonceAt(context.Background(), sender)
+		if err != nil {
+			return nil, &RPCError{Kind: NonceFailed, Err: err}
+		}
+
+		state.sync(pendingNonce)
+		state.synced = true
+	}
+
+	return state, nil
+}
+
+// Must be called with the lock held. Written to a temp file first,
+// so a crash never leaves the state half-written.
+func (nm *NonceManager) save() error {
+	if nm.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(nm.senders)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(nm.path), 0700); err != nil {
+		return err
+	}
+
+	tmpPath := nm.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, nm.path)
+}
+
+// Nonces below the node's pending one are used up
+func (state *senderNonces) sync(pendingNonce uint64) {
+	if state.Next < pendingNonce {
+		state.Next = pendingNonce
+	}
+
+	released := state.Released[:0]
+	for _, nonce := range state.Released {
+		if nonce >= pendingNonce {
+			released = append(released, nonce)
+		}
+	}
+	state.Released = released
+}
+
+func (state *senderNonces) release(nonce uint64) {
+	if nonce >= state.Next || state.isReleased(nonce) {
+		return
+	}
+
+	state.Released = append(state.Released, nonce)
+	sort.Slice(state.Released, func(i, j int) bool { return state.Released[i] < state.Released[j] })
+
+	// Released nonces right below Next are simply handed out again
+	for len(state.Released) > 0 && state.Released[len(state.Released)-1] == state.Next-1 {
+		state.Released = state.Released[:len(state.Released)-1]
+		state.Next--
+	}
+}
+
+func (state *senderNonces) isReleased(nonce uint64) bool {
+	for _, released := range state.Released {
+		if released == nonce {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Messages differ between node implementations
+func isNonceTooLow(err error) bool {
+	message := strings.ToLower(err.Error())
+
+	return strings.Contains(message, "nonce too low") ||
+		strings.Contains(message, "nonce has already been used") ||
+		strings.Contains(message, "invalid transaction nonce")
+}
+
+func isAlreadyKnown(err error) bool {
+	message := strings.ToLower(err.Error())
+
+	return strings.Contains(message, "already known") ||
+		strings.Contains(message, "known transaction")
+}
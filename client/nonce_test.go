@@ -0,0 +1,352 @@
+package client
+
+import (
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Answers just what the nonce manager and building a transaction need
+type testNode struct {
+	mu      sync.Mutex
+	pending uint64
+	sent    []uint64
+}
+
+func (n *testNode) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return hexutil.Uint64(n.pending)
+}
+
+func (n *testNode) SendRawTransaction(raw hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return common.Hash{}, err
+	}
+
+	n.mu.Lock()
+	n.sent = append(n.sent, tx.Nonce())
+	n.mu.Unlock()
+
+	return tx.Hash(), nil
+}
+
+func (n *testNode) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(5))
+}
+
+func (n *testNode) EstimateGas(args map[string]interface{}) hexutil.Uint64 {
+	return 21000
+}
+
+func (n *testNode) GetBlockByNumber(number string, full bool) *types.Header {
+	return &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int), BaseFee: big.NewInt(7)}
+}
+
+func (n *testNode) MaxPriorityFeePerGas() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(2))
+}
+
+func (n *testNode) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(100))
+}
+
+func (n *testNode) setPending(pending uint64) {
+	n.mu.Lock()
+	n.pending = pending
+	n.mu.Unlock()
+}
+
+func newTestNode(t *testing.T, pending uint64) (*testNode, *Client) {
+	t.Helper()
+
+	node := &testNode{pending: pending}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", node); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	client, err := NewClient(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return node, client
+}
+
+func newTestNonceManager(t *testing.T, client *Client, path string) *NonceManager {
+	t.Helper()
+
+	nm, err := NewNonceManager(client, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return nm
+}
+
+func next(t *testing.T, nm *NonceManager, sender common.Address) uint64 {
+	t.Helper()
+
+	nonce, err := nm.Next(sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return nonce
+}
+
+var testSender = common.HexToAddress("0x9")
+
+func TestNonceManagerNext(t *testing.T) {
+	_, client := newTestNode(t, 10)
+	nm := newTestNonceManager(t, client, "")
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		nonces = make(map[uint64]bool)
+	)
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			nonce, err := nm.Next(testSender)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if nonces[nonce] {
+				t.Errorf("nonce %d handed out twice", nonce)
+			}
+			nonces[nonce] = true
+		}()
+	}
+	wg.Wait()
+
+	for nonce := uint64(10); nonce < 40; nonce++ {
+		if !nonces[nonce] {
+			t.Fatalf("nonce %d not handed out", nonce)
+		}
+	}
+}
+
+func TestNonceManagerRelease(t *testing.T) {
+	_, client := newTestNode(t, 5)
+	nm := newTestNonceManager(t, client, "")
+
+	for want := uint64(5); want < 9; want++ {
+		if nonce := next(t, nm, testSender); nonce != want {
+			t.Fatalf("nonce = %d, want %d", nonce, want)
+		}
+	}
+
+	// Not sent, so handed out again, lowest first
+	if err := nm.Done(testSender, 7, errors.New("insufficient funds for gas * price + value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.Release(testSender, 6); err != nil {
+		t.Fatal(err)
+	}
+	if nonce := next(t, nm, testSender); nonce != 6 {
+		t.Fatalf("nonce = %d, want 6", nonce)
+	}
+	if nonce := next(t, nm, testSender); nonce != 7 {
+		t.Fatalf("nonce = %d, want 7", nonce)
+	}
+
+	// The last one is simply handed out again
+	if err := nm.Release(testSender, 8); err != nil {
+		t.Fatal(err)
+	}
+	if nonce := next(t, nm, testSender); nonce != 8 {
+		t.Fatalf("nonce = %d, want 8", nonce)
+	}
+
+	// Never handed out
+	if err := nm.Release(testSender, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.Release(testSender, 50); err != nil {
+		t.Fatal(err)
+	}
+	if nonce := next(t, nm, testSender); nonce != 9 {
+		t.Fatalf("nonce = %d, want 9", nonce)
+	}
+}
+
+func TestNonceManagerReplacement(t *testing.T) {
+	_, client := newTestNode(t, 5)
+	nm := newTestNonceManager(t, client, "")
+
+	for _, want := range []uint64{5, 6} {
+		nonce := next(t, nm, testSender)
+		if nonce != want {
+			t.Fatalf("nonce = %d, want %d", nonce, want)
+		}
+		if err := nm.Done(testSender, nonce, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A replacement of 5 that the node refuses doesn't free 5,
+	// the original transaction is still pending
+	if err := nm.Done(testSender, 5, errors.New("replacement transaction underpriced")); err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.Release(testSender, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	if nonce := next(t, nm, testSender); nonce != 7 {
+		t.Fatalf("nonce = %d, want 7", nonce)
+	}
+}
+
+func TestNonceManagerPersistence(t *testing.T) {
+	node, client := newTestNode(t, 10)
+	path := filepath.Join(t.TempDir(), "nonces", "nonces.json")
+	nm := newTestNonceManager(t, client, path)
+
+	for i := 0; i < 5; i++ {
+		next(t, nm, testSender)
+	}
+	if err := nm.Release(testSender, 12); err != nil {
+		t.Fatal(err)
+	}
+
+	nm = newTestNonceManager(t, client, path)
+	if nonce := next(t, nm, testSender); nonce != 12 {
+		t.Fatalf("nonce = %d, want 12", nonce)
+	}
+	if nonce := next(t, nm, testSender); nonce != 15 {
+		t.Fatalf("nonce = %d, want 15", nonce)
+	}
+
+	// The node is ahead after a restart, e.g. the key was used elsewhere
+	node.setPending(100)
+	nm = newTestNonceManager(t, client, path)
+	if nonce := next(t, nm, testSender); nonce != 100 {
+		t.Fatalf("nonce = %d, want 100", nonce)
+	}
+}
+
+func TestNonceManagerResync(t *testing.T) {
+	node, client := newTestNode(t, 5)
+	nm := newTestNonceManager(t, client, "")
+
+	nonce := next(t, nm, testSender)
+
+	node.setPending(20)
+	if err := nm.Done(testSender, nonce, errors.New("nonce too low: address 0x9, tx: 5 state: 20")); err != nil {
+		t.Fatal(err)
+	}
+	if nonce := next(t, nm, testSender); nonce != 20 {
+		t.Fatalf("nonce = %d, want 20", nonce)
+	}
+}
+
+func TestNonceManagerGaps(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	node, client := newTestNode(t, 5)
+	nm := newTestNonceManager(t, client, "")
+
+	for i := 0; i < 5; i++ {
+		next(t, nm, sender)
+	}
+
+	// In flight, the node may just not have them yet
+	gaps, err := nm.Gaps(sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 0 {
+		t.Fatalf("gaps = %v, want none", gaps)
+	}
+
+	for _, nonce := range []uint64{5, 6, 7, 9} {
+		if err := nm.Done(sender, nonce, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := nm.Release(sender, 8); err != nil {
+		t.Fatal(err)
+	}
+
+	// 5 was dropped by the node
+	gaps, err = nm.Gaps(sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gaps, []uint64{5, 8}) {
+		t.Fatalf("gaps = %v, want [5 8]", gaps)
+	}
+
+	hashes, err := nm.FillGaps(sender, func(tx *types.Transaction) (*types.Transaction, error) {
+		return types.SignTx(tx, types.LatestSignerForChainID(big.NewInt(5)), key)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 2 || !reflect.DeepEqual(node.sent, []uint64{5, 8}) {
+		t.Fatalf("hashes = %v, sent = %v", hashes, node.sent)
+	}
+
+	node.setPending(10)
+	gaps, err = nm.Gaps(sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 0 {
+		t.Fatalf("gaps = %v, want none", gaps)
+	}
+	if nonce := next(t, nm, sender); nonce != 10 {
+		t.Fatalf("nonce = %d, want 10", nonce)
+	}
+}
+
+func TestNonceManagerSaveFailure(t *testing.T) {
+	_, client := newTestNode(t, 5)
+
+	// A file where the directory should be
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	nm := newTestNonceManager(t, client, "")
+	nm.path = filepath.Join(dir, "file", "nonces.json")
+	if _, err := nm.Next(testSender); err == nil {
+		t.Fatal("Next succeeded without saving")
+	}
+
+	nm.path = ""
+	if nonce := next(t, nm, testSender); nonce != 5 {
+		t.Fatalf("nonce = %d, want 5", nonce)
+	}
+}
@@ -1,6 +1,9 @@
 package client
 
 import (
+	"fmt"
+	"math/big"
+
 	"github.com/0xNSHuman/dapp-tools/common"
 )
 
@@ -11,6 +14,15 @@ const (
 	BadRPCConnection
 	GasEstimateFailed
 	TransactionFailed
+	ChainIDFailed
+	NonceFailed
+	GasPriceFailed
+	GasTipFailed
+	ExecutionReverted
+	HeaderFailed
+	FeeHistoryFailed
+	FeeCeilingExceeded
+	InvalidFeeStrategy
 )
 
 func (e ClientError) Error() string {
@@ -21,7 +33,105 @@ func (e ClientError) Error() string {
 		return "Transaction failed"
 	case GasEstimateFailed:
 		return "Gas estimate failed"
+	case ChainIDFailed:
+		return "Chain ID request failed"
+	case NonceFailed:
+		return "Nonce request failed"
+	case GasPriceFailed:
+		return "Gas price request failed"
+	case GasTipFailed:
+		return "Gas tip request failed"
+	case ExecutionReverted:
+		return "Execution reverted"
+	case HeaderFailed:
+		return "Header request failed"
+	case FeeHistoryFailed:
+		return "Fee history request failed"
+	case FeeCeilingExceeded:
+		return "Base fee exceeds the fee ceiling"
+	case InvalidFeeStrategy:
+		return "Invalid fee strategy"
 	default:
 		return "Unknown"
 	}
 }
+
+type TransactionType uint8
+
+const (
+	// Dynamic fee transaction on London chains. Otherwise a legacy one,
+	// or an access list one if the message has an access list.
+	TransactionTypeAuto TransactionType = iota
+	TransactionTypeLegacy
+	TransactionTypeAccessList
+	TransactionTypeDynamicFee
+)
+
+type TransactionOptions struct {
+	// Applied to the suggested tip, or to the gas price
+	// of legacy and access list transactions. Zero means 1.
+	// Not used with a FeeStrategy.
+	GasMultiplier float64
+
+	Type TransactionType
+
+	// Nil means the node's suggested gas price as the fee cap
+	FeeStrategy FeeStrategy
+
+	// Hands out the nonce instead of the node's pending nonce.
+	// NonceManager.Done must be called once the transaction is sent.
+	Nonces *NonceManager
+
+	// Overrides the nonce, e.g. to replace a pending transaction
+	Nonce *uint64
+
+	// Gas limit, zero means estimated
+	Gas uint64
+}
+
+// A ClientError with the RPC error behind it. Matches both of them
+// with errors.Is, e.g. errors.Is(err, ExecutionReverted).
+type RPCError struct {
+	Kind ClientError
+	Err  error
+
+	// Set for ExecutionReverted if the node returned the revert data
+	Revert *Revert
+}
+
+func (e *RPCError) Error() string {
+	switch {
+	case e.Revert != nil && e.Revert.Reason != "":
+		return e.Kind.Error() + ": " + e.Revert.Reason
+	case e.Revert != nil && e.Revert.PanicCode != nil:
+		return fmt.Sprintf("%s: panic code 0x%x", e.Kind.Error(), e.Revert.PanicCode)
+	}
+
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *RPCError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *RPCError) Unwrap() error {
+	return e.Err
+}
+
+// Revert data of a call. Reason is set for require/revert with a message,
+// PanicCode for failed asserts, overflows etc. Custom errors only have Data.
+type Revert struct {
+	Data      []byte
+	Reason    string
+	PanicCode *big.Int
+}
+
+// 4-byte selector of a custom error, zero if there's none
+func (r *Revert) Selector() [4]byte {
+	var selector [4]byte
+	if len(r.Data) >= 4 {
+		copy(selector[:], r.Data[:4])
+	}
+
+	return selector
+}
@@ -8,6 +8,8 @@ const (
 	ErrorDomainClient
 	ErrorDomainWallet
 	ErrorDomainSchedule
+	ErrorDomainWalletRPC
+	ErrorDomainSIWE
 )
 
 type MetaError uint
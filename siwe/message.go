@@ -0,0 +1,266 @@
+package siwe
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	Version = "1"
+
+	header         = " wants you to sign in with your Ethereum account:"
+	nonceAlphabet  = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	nonceLength    = 17
+	minNonceLength = 8
+)
+
+// EIP-4361 message. Optional fields are left out of the text when empty.
+type Message struct {
+	// Optional URI scheme of the requesting origin, e.g. "https"
+	Scheme string
+
+	// Host of the requesting origin, with a port if not the default one
+	Domain string
+
+	Address   gethcommon.Address
+	Statement string
+	URI       string
+	Version   string
+	ChainID   uint64
+	Nonce     string
+	IssuedAt  time.Time
+
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// Random alphanumeric nonce, see also NonceStore
+func NewNonce() (string, error) {
+	var nonce strings.Builder
+
+	max := big.NewInt(int64(len(nonceAlphabet)))
+	for i := 0; i < nonceLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		nonce.WriteByte(nonceAlphabet[n.Int64()])
+	}
+
+	return nonce.String(), nil
+}
+
+// Checks that the message renders to a text ParseMessage accepts,
+// e.g. that the statement is a single line and the nonce is long enough
+func (m *Message) Validate() error {
+	switch {
+	case m.Domain == "" || strings.ContainsAny(m.Domain, " /\r\n"):
+		return fmt.Errorf("%w: invalid domain %q", InvalidMessage, m.Domain)
+	case strings.ContainsAny(m.Scheme, " :/\r\n"):
+		return fmt.Errorf("%w: invalid scheme %q", InvalidMessage, m.Scheme)
+	case strings.ContainsAny(m.Statement, "\r\n"):
+		return fmt.Errorf("%w: statement must be a single line", InvalidMessage)
+	case m.URI == "" || strings.ContainsAny(m.URI, " \r\n"):
+		return fmt.Errorf("%w: invalid URI %q", InvalidMessage, m.URI)
+	case m.Version != "" && m.Version != Version:
+		return fmt.Errorf("%w: unsupported version %q", InvalidMessage, m.Version)
+	case !validNonce(m.Nonce):
+		return fmt.Errorf("%w: nonce must be at least %d alphanumeric characters", InvalidMessage, minNonceLength)
+	case m.IssuedAt.IsZero():
+		return fmt.Errorf("%w: missing issued at time", InvalidMessage)
+	case strings.ContainsAny(m.RequestID, "\r\n"):
+		return fmt.Errorf("%w: request ID must be a single line", InvalidMessage)
+	}
+
+	for _, resource := range m.Resources {
+		if resource == "" || strings.ContainsAny(resource, " \r\n") {
+			return fmt.Errorf("%w: invalid resource %q", InvalidMessage, resource)
+		}
+	}
+
+	return nil
+}
+
+// The message text to be signed, empty if the message isn't valid,
+// see Validate. Parsed messages may render slightly differently,
+// e.g. times lose trailing zeros, so signatures have to be checked
+// against the text as received.
+func (m *Message) String() string {
+	if err := m.Validate(); err != nil {
+		return ""
+	}
+
+	var b strings.Builder
+
+	if m.Scheme != "" {
+		b.WriteString(m.Scheme + "://")
+	}
+	b.WriteString(m.Domain + header + "\n")
+	b.WriteString(m.Address.Hex() + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+
+	version := m.Version
+	if version == "" {
+		version = Version
+	}
+
+	b.WriteString("URI: " + m.URI + "\n")
+	b.WriteString("Version: " + version + "\n")
+	b.WriteString("Chain ID: " + strconv.FormatUint(m.ChainID, 10) + "\n")
+	b.WriteString("Nonce: " + m.Nonce + "\n")
+	b.WriteString("Issued At: " + m.IssuedAt.Format(time.RFC3339Nano))
+
+	if m.ExpirationTime != nil {
+		b.WriteString("\nExpiration Time: " + m.ExpirationTime.Format(time.RFC3339Nano))
+	}
+	if m.NotBefore != nil {
+		b.WriteString("\nNot Before: " + m.NotBefore.Format(time.RFC3339Nano))
+	}
+	if m.RequestID != "" {
+		b.WriteString("\nRequest ID: " + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\nResources:")
+		for _, resource := range m.Resources {
+			b.WriteString("\n- " + resource)
+		}
+	}
+
+	return b.String()
+}
+
+// Parses the text of an EIP-4361 message. The address has to be
+// EIP-55 checksummed, as the spec requires.
+func ParseMessage(text string) (*Message, error) {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	m := new(Message)
+
+	if len(lines) < 3 || !strings.HasSuffix(lines[0], header) || lines[2] != "" {
+		return nil, InvalidMessage
+	}
+
+	m.Domain = strings.TrimSuffix(lines[0], header)
+	if scheme, domain, ok := strings.Cut(m.Domain, "://"); ok {
+		m.Scheme, m.Domain = scheme, domain
+	}
+	if m.Domain == "" || strings.ContainsAny(m.Domain, " /") {
+		return nil, InvalidMessage
+	}
+
+	if !gethcommon.IsHexAddress(lines[1]) || gethcommon.HexToAddress(lines[1]).Hex() != lines[1] {
+		return nil, InvalidMessage
+	}
+	m.Address = gethcommon.HexToAddress(lines[1])
+
+	lines = lines[3:]
+	if len(lines) > 0 && lines[0] != "" {
+		m.Statement = lines[0]
+		lines = lines[1:]
+	}
+	if len(lines) == 0 || lines[0] != "" {
+		return nil, InvalidMessage
+	}
+	lines = lines[1:]
+
+	p := &fieldParser{lines: lines}
+
+	m.URI = p.required("URI: ")
+	m.Version = p.required("Version: ")
+	chainId := p.required("Chain ID: ")
+	m.Nonce = p.required("Nonce: ")
+	issuedAt := p.required("Issued At: ")
+	expirationTime, hasExpirationTime := p.optional("Expiration Time: ")
+	notBefore, hasNotBefore := p.optional("Not Before: ")
+	m.RequestID, _ = p.optional("Request ID: ")
+
+	if _, ok := p.optional("Resources:"); ok {
+		for len(p.lines) > 0 && strings.HasPrefix(p.lines[0], "- ") {
+			m.Resources = append(m.Resources, strings.TrimPrefix(p.lines[0], "- "))
+			p.lines = p.lines[1:]
+		}
+	}
+
+	if p.failed || len(p.lines) > 0 || m.URI == "" || m.Version != Version || !validNonce(m.Nonce) {
+		return nil, InvalidMessage
+	}
+
+	var err error
+	if m.ChainID, err = strconv.ParseUint(chainId, 10, 64); err != nil {
+		return nil, InvalidMessage
+	}
+	if m.IssuedAt, err = time.Parse(time.RFC3339Nano, issuedAt); err != nil {
+		return nil, InvalidMessage
+	}
+	if hasExpirationTime {
+		if m.ExpirationTime, err = parseTime(expirationTime); err != nil {
+			return nil, InvalidMessage
+		}
+	}
+	if hasNotBefore {
+		if m.NotBefore, err = parseTime(notBefore); err != nil {
+			return nil, InvalidMessage
+		}
+	}
+
+	return m, nil
+}
+
+// Reads "Tag: value" lines in the order the spec defines
+type fieldParser struct {
+	lines  []string
+	failed bool
+}
+
+func (p *fieldParser) required(tag string) string {
+	value, ok := p.optional(tag)
+	if !ok {
+		p.failed = true
+	}
+
+	return value
+}
+
+func (p *fieldParser) optional(tag string) (string, bool) {
+	if len(p.lines) == 0 || !strings.HasPrefix(p.lines[0], tag) {
+		return "", false
+	}
+
+	value := strings.TrimPrefix(p.lines[0], tag)
+	p.lines = p.lines[1:]
+
+	return value, true
+}
+
+func parseTime(value string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func validNonce(nonce string) bool {
+	if len(nonce) < minNonceLength {
+		return false
+	}
+
+	for _, c := range nonce {
+		if !strings.ContainsRune(nonceAlphabet, c) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,143 @@
+package siwe
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// Example from EIP-4361
+const exampleMessage = `service.org wants you to sign in with your Ethereum account:
+0xe5A12547fe4E872D192E3eCecb76F2Ce1aeA4946
+
+I accept the ServiceOrg Terms of Service: https://service.org/tos
+
+URI: https://service.org/login
+Version: 1
+Chain ID: 1
+Nonce: 32891757
+Issued At: 2021-09-30T16:25:24Z
+Resources:
+- ipfs://Qme7ss3ARVgxv6rXqVPiikMJ8u2NLgmgszg13pYrDKEoiu
+- https://example.com/my-web2-claim.json`
+
+func testMessage() *Message {
+	expirationTime := time.Date(2021, 10, 30, 16, 25, 24, 0, time.UTC)
+
+	return &Message{
+		Scheme:         "https",
+		Domain:         "example.com:3000",
+		Address:        gethcommon.HexToAddress("0xe5A12547fe4E872D192E3eCecb76F2Ce1aeA4946"),
+		Statement:      "Sign in to Example",
+		URI:            "https://example.com:3000/login",
+		Version:        Version,
+		ChainID:        10,
+		Nonce:          "abcdefgh12",
+		IssuedAt:       time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC),
+		ExpirationTime: &expirationTime,
+		RequestID:      "request-1",
+		Resources:      []string{"ipfs://Qme7ss3ARVgxv6rXqVPiikMJ8u2NLgmgszg13pYrDKEoiu"},
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	m, err := ParseMessage(exampleMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Domain != "service.org" || m.Nonce != "32891757" || m.ChainID != 1 || len(m.Resources) != 2 {
+		t.Fatalf("message = %+v", m)
+	}
+	if m.String() != exampleMessage {
+		t.Fatalf("rendered as\n%s", m.String())
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := testMessage()
+
+	parsed, err := ParseMessage(m.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, m) {
+		t.Fatalf("parsed = %+v, want %+v", parsed, m)
+	}
+
+	// Optional fields left out
+	m = &Message{
+		Domain:   "example.com",
+		Address:  m.Address,
+		URI:      "https://example.com",
+		Nonce:    "abcdefgh12",
+		IssuedAt: m.IssuedAt,
+	}
+
+	parsed, err = ParseMessage(m.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Version = Version
+	if !reflect.DeepEqual(parsed, m) {
+		t.Fatalf("parsed = %+v, want %+v", parsed, m)
+	}
+}
+
+func TestParseMessageInvalid(t *testing.T) {
+	tests := map[string]string{
+		"lowercase address": strings.Replace(exampleMessage, "0xe5A12547fe4E872D192E3eCecb76F2Ce1aeA4946", "0xe5a12547fe4e872d192e3ececb76f2ce1aea4946", 1),
+		"missing URI":       strings.Replace(exampleMessage, "URI: https://service.org/login\n", "", 1),
+		"other version":     strings.Replace(exampleMessage, "Version: 1", "Version: 2", 1),
+		"short nonce":       strings.Replace(exampleMessage, "Nonce: 32891757", "Nonce: 123", 1),
+		"fields reordered":  strings.Replace(exampleMessage, "Version: 1\nChain ID: 1", "Chain ID: 1\nVersion: 1", 1),
+		"trailing line":     exampleMessage + "\nextra",
+		"two statements":    strings.Replace(exampleMessage, "Terms of Service: https://service.org/tos", "Terms\nof Service", 1),
+	}
+
+	for name, text := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseMessage(text); err != InvalidMessage {
+				t.Fatalf("err = %v, want InvalidMessage", err)
+			}
+		})
+	}
+}
+
+func TestMessageValidate(t *testing.T) {
+	tests := map[string]func(m *Message){
+		"multiline statement": func(m *Message) { m.Statement = "Sign in\nto Example" },
+		"empty nonce":         func(m *Message) { m.Nonce = "" },
+		"short nonce":         func(m *Message) { m.Nonce = "abc" },
+		"nonce with symbols":  func(m *Message) { m.Nonce = "abcdefgh-12" },
+		"empty URI":           func(m *Message) { m.URI = "" },
+		"empty domain":        func(m *Message) { m.Domain = "" },
+		"domain with a path":  func(m *Message) { m.Domain = "example.com/login" },
+		"scheme with a colon": func(m *Message) { m.Scheme = "https:" },
+		"other version":       func(m *Message) { m.Version = "2" },
+		"missing issued at":   func(m *Message) { m.IssuedAt = time.Time{} },
+		"multiline resource":  func(m *Message) { m.Resources = []string{"a\nb"} },
+	}
+
+	for name, change := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := testMessage()
+			change(m)
+
+			if err := m.Validate(); !errors.Is(err, InvalidMessage) {
+				t.Fatalf("err = %v, want InvalidMessage", err)
+			}
+			if m.String() != "" {
+				t.Fatalf("invalid message rendered as\n%s", m.String())
+			}
+		})
+	}
+
+	if err := testMessage().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,73 @@
+package siwe
+
+import (
+	"sync"
+	"time"
+)
+
+// Issues nonces for SIWE messages and accepts each of them only once,
+// which protects against replaying signed messages
+type NonceStore interface {
+	Issue() (string, error)
+
+	// Fails with InvalidNonce if the nonce wasn't issued, has expired
+	// or has been consumed already
+	Consume(nonce string) error
+}
+
+// NonceStore kept in memory, nonces expire after a TTL
+type MemoryNonceStore struct {
+	ttl time.Duration
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+func NewMemoryNonceStore(ttl time.Duration) *MemoryNonceStore {
+	return &MemoryNonceStore{
+		ttl:    ttl,
+		nonces: make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryNonceStore) Issue() (string, error) {
+	nonce, err := NewNonce()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+	s.nonces[nonce] = now.Add(s.ttl)
+
+	return nonce, nil
+}
+
+func (s *MemoryNonceStore) Consume(nonce string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.nonces[nonce]
+	if !ok {
+		return InvalidNonce
+	}
+	delete(s.nonces, nonce)
+
+	if !time.Now().Before(expiresAt) {
+		return InvalidNonce
+	}
+
+	return nil
+}
+
+// Must be called with the lock held
+func (s *MemoryNonceStore) prune(now time.Time) {
+	for nonce, expiresAt := range s.nonces {
+		if !now.Before(expiresAt) {
+			delete(s.nonces, nonce)
+		}
+	}
+}
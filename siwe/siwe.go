@@ -0,0 +1,74 @@
+package siwe
+
+import (
+	"time"
+
+	"github.com/0xNSHuman/dapp-tools/utils"
+	"github.com/0xNSHuman/dapp-tools/wallet"
+)
+
+type VerifyOptions struct {
+	// Expected domain, checked if set
+	Domain string
+
+	// Expected chain ID, checked if set
+	ChainID uint64
+
+	// Nonces issued to clients. Without a store the nonce isn't checked,
+	// so the caller has to protect against replays.
+	Nonces NonceStore
+
+	// Time to check the message validity at, defaults to now
+	Time time.Time
+}
+
+// Signs the message with the wallet as an EIP-191 personal message
+func Sign(wk *wallet.WalletKeeper, m *Message, autosign bool) ([]byte, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return wk.SignMessage([]byte(m.String()), m.Address, autosign)
+}
+
+// Verifies a signed message as received from a client. The nonce is
+// consumed only once everything else checks out, so invalid requests
+// can't burn nonces issued to others.
+// Only EOA signatures are supported, not EIP-1271 contract wallets.
+func Verify(text string, signature []byte, opts VerifyOptions) (*Message, error) {
+	m, err := ParseMessage(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Domain != "" && opts.Domain != m.Domain {
+		return nil, DomainMismatch
+	}
+	if opts.ChainID != 0 && opts.ChainID != m.ChainID {
+		return nil, ChainMismatch
+	}
+
+	now := opts.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return nil, MessageExpired
+	}
+	if m.NotBefore != nil && now.Before(*m.NotBefore) {
+		return nil, MessageNotYetValid
+	}
+
+	ok, err := utils.VerifyMessageSignature([]byte(text), signature, m.Address)
+	if err != nil || !ok {
+		return nil, InvalidSignature
+	}
+
+	if opts.Nonces != nil {
+		if err := opts.Nonces.Consume(m.Nonce); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,182 @@
+package siwe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0xNSHuman/dapp-tools/wallet"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type testUI struct{}
+
+func (testUI) EnterPassphrase() (string, error) {
+	return "pw", nil
+}
+
+func (testUI) ApproveRequest(request *wallet.ApprovalRequest) (bool, error) {
+	return true, nil
+}
+
+func newTestWallet(t *testing.T) (*wallet.WalletKeeper, gethcommon.Address) {
+	t.Helper()
+
+	wk, err := wallet.NewWalletKeeperWithOptions(testUI{}, wallet.Options{
+		Path:        t.TempDir(),
+		LightScrypt: true,
+		Storage:     wallet.NewMemoryStorage(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wk.ImportWallet(wallet.ImportModePrivateKey, crypto.FromECDSA(key), "pw"); err != nil {
+		t.Fatal(err)
+	}
+
+	return wk, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	wk, address := newTestWallet(t)
+	nonces := NewMemoryNonceStore(time.Minute)
+
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	expirationTime := now.Add(time.Hour)
+	notBefore := now.Add(-time.Minute)
+
+	m := &Message{
+		Domain:         "localhost:3000",
+		Address:        address,
+		Statement:      "Sign in",
+		URI:            "http://localhost:3000",
+		ChainID:        1,
+		Nonce:          nonce,
+		IssuedAt:       now,
+		ExpirationTime: &expirationTime,
+		NotBefore:      &notBefore,
+	}
+
+	signature, err := Sign(wk, m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := m.String()
+
+	tests := []struct {
+		name string
+		text string
+		opts VerifyOptions
+		err  error
+	}{
+		{"other domain", text, VerifyOptions{Domain: "evil.com", Nonces: nonces}, DomainMismatch},
+		{"other chain", text, VerifyOptions{ChainID: 5, Nonces: nonces}, ChainMismatch},
+		{"expired", text, VerifyOptions{Time: expirationTime, Nonces: nonces}, MessageExpired},
+		{"not yet valid", text, VerifyOptions{Time: notBefore.Add(-time.Second), Nonces: nonces}, MessageNotYetValid},
+		{"other text", text + "\n- https://evil.com", VerifyOptions{Nonces: nonces}, InvalidMessage},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Verify(test.text, signature, test.opts); err != test.err {
+				t.Fatalf("err = %v, want %v", err, test.err)
+			}
+		})
+	}
+
+	// Rejected requests don't burn the nonce
+	verified, err := Verify(text, signature, VerifyOptions{Domain: "localhost:3000", ChainID: 1, Nonces: nonces})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Address != address || verified.Nonce != nonce {
+		t.Fatalf("verified = %+v", verified)
+	}
+
+	// Replays are
+	if _, err := Verify(text, signature, VerifyOptions{Nonces: nonces}); err != InvalidNonce {
+		t.Fatalf("err = %v, want InvalidNonce", err)
+	}
+}
+
+func TestVerifyWrongSigner(t *testing.T) {
+	wk, address := newTestWallet(t)
+	_, other := newTestWallet(t)
+
+	m := &Message{
+		Domain:   "example.com",
+		Address:  address,
+		URI:      "https://example.com",
+		ChainID:  1,
+		Nonce:    "abcdefgh12",
+		IssuedAt: time.Now().UTC(),
+	}
+	signature, err := Sign(wk, m, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.Address = other
+	if _, err := Verify(m.String(), signature, VerifyOptions{}); err != InvalidSignature {
+		t.Fatalf("err = %v, want InvalidSignature", err)
+	}
+}
+
+func TestSignInvalidMessage(t *testing.T) {
+	wk, address := newTestWallet(t)
+
+	m := &Message{
+		Domain:    "example.com",
+		Address:   address,
+		Statement: "Sign in\nURI: https://evil.com",
+		URI:       "https://example.com",
+		Nonce:     "abcdefgh12",
+		IssuedAt:  time.Now().UTC(),
+	}
+
+	if _, err := Sign(wk, m, false); !errors.Is(err, InvalidMessage) {
+		t.Fatalf("err = %v, want InvalidMessage", err)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	nonces := NewMemoryNonceStore(time.Minute)
+
+	nonce, err := nonces.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validNonce(nonce) {
+		t.Fatalf("invalid nonce %q", nonce)
+	}
+
+	if err := nonces.Consume("unknown123"); err != InvalidNonce {
+		t.Fatalf("err = %v, want InvalidNonce", err)
+	}
+	if err := nonces.Consume(nonce); err != nil {
+		t.Fatal(err)
+	}
+	if err := nonces.Consume(nonce); err != InvalidNonce {
+		t.Fatalf("err = %v, want InvalidNonce", err)
+	}
+
+	expired := NewMemoryNonceStore(0)
+	nonce, err = expired.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := expired.Consume(nonce); err != InvalidNonce {
+		t.Fatalf("err = %v, want InvalidNonce", err)
+	}
+}
@@ -0,0 +1,39 @@
+package siwe
+
+import (
+	"github.com/0xNSHuman/dapp-tools/common"
+)
+
+type SIWEError uint
+
+const (
+	Unknown SIWEError = common.ErrorDomainSIWE + iota
+	InvalidMessage
+	InvalidSignature
+	DomainMismatch
+	ChainMismatch
+	MessageExpired
+	MessageNotYetValid
+	InvalidNonce
+)
+
+func (e SIWEError) Error() string {
+	switch e {
+	case InvalidMessage:
+		return "Invalid SIWE message"
+	case InvalidSignature:
+		return "Invalid SIWE signature"
+	case DomainMismatch:
+		return "SIWE domain mismatch"
+	case ChainMismatch:
+		return "SIWE chain ID mismatch"
+	case MessageExpired:
+		return "SIWE message expired"
+	case MessageNotYetValid:
+		return "SIWE message not yet valid"
+	case InvalidNonce:
+		return "Unknown, expired or used SIWE nonce"
+	default:
+		return "Unknown"
+	}
+}
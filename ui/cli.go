@@ -38,18 +38,18 @@ func NewCLI() *CLI {
 
 func (cli *CLI) bindIO() {
 	go func() {
-		request := <-cli.ReqChannel
+		for request := range cli.ReqChannel {
+			fmt.Printf("%s: ", request.title)
 
-		fmt.Printf("%s: ", request.title)
-		defer fmt.Printf("\n")
+			input, err := cli.reader.ReadString('\n')
+			if err != nil {
+				fmt.Println(err)
+			}
 
-		input, err := cli.reader.ReadString('\n')
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		input = strings.Trim(input, "\r\n")
+			input = strings.Trim(input, "\r\n")
+			fmt.Printf("\n")
 
-		cli.ResChannel <- UserInputResponse{input}
+			cli.ResChannel <- UserInputResponse{input}
+		}
 	}()
 }
@@ -1,7 +1,20 @@
 package ui
 
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/0xNSHuman/dapp-tools/wallet"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
 type WalletCLI struct {
 	cli *CLI
+
+	// Requests may come from several goroutines, e.g. the JSON-RPC server
+	mu sync.Mutex
 }
 
 func NewWalletCLI() *WalletCLI {
@@ -11,8 +24,88 @@ func NewWalletCLI() *WalletCLI {
 }
 
 func (wcli *WalletCLI) EnterPassphrase() (string, error) {
-	wcli.cli.ReqChannel <- UserInputRequest{"Enter wallet passphrase"}
+	return wcli.prompt("Enter wallet passphrase"), nil
+}
+
+func (wcli *WalletCLI) ApproveRequest(request *wallet.ApprovalRequest) (bool, error) {
+	wcli.mu.Lock()
+	defer wcli.mu.Unlock()
+
+	fmt.Println("Signing request:", request.Method)
+	if request.Origin != "" {
+		fmt.Println("Origin:", request.Origin)
+	}
+	fmt.Println("Account:", request.Account.Hex())
+	if request.ChainID != nil {
+		fmt.Println("Chain ID:", request.ChainID)
+	}
+
+	if len(request.Transactions) > 1 {
+		fmt.Println("Transactions:", len(request.Transactions))
+		fmt.Println("Total value:", request.TotalValue(), "wei")
+		fmt.Println("Total max fee:", request.TotalMaxFee(), "wei")
+		fmt.Println()
+	}
+
+	for i, tx := range request.Transactions {
+		if len(request.Transactions) > 1 {
+			fmt.Printf("Transaction #%d\n", i+1)
+		}
+
+		if to := tx.To(); to != nil {
+			fmt.Println("To:", to.Hex())
+		} else {
+			fmt.Println("To: contract creation")
+		}
+		fmt.Println("Value:", tx.Value(), "wei")
+		fmt.Println("Nonce:", tx.Nonce())
+		fmt.Println("Gas limit:", tx.Gas())
+		if tx.Type() == types.DynamicFeeTxType {
+			fmt.Println("Max fee per gas:", tx.GasFeeCap(), "wei")
+			fmt.Println("Max priority fee per gas:", tx.GasTipCap(), "wei")
+		} else {
+			fmt.Println("Gas price:", tx.GasPrice(), "wei")
+		}
+		if len(tx.Data()) > 0 {
+			fmt.Printf("Data: 0x%x\n", tx.Data())
+		}
+	}
+
+	if len(request.Data) > 0 {
+		if isPrintable(request.Data) {
+			fmt.Println("Data:", string(request.Data))
+		} else {
+			fmt.Printf("Data: 0x%x\n", request.Data)
+		}
+	}
+	fmt.Println()
+
+	answer := wcli.promptLocked("Approve? (y/N)")
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes", nil
+}
+
+func (wcli *WalletCLI) prompt(title string) string {
+	wcli.mu.Lock()
+	defer wcli.mu.Unlock()
+
+	return wcli.promptLocked(title)
+}
+
+func (wcli *WalletCLI) promptLocked(title string) string {
+	wcli.cli.ReqChannel <- UserInputRequest{title}
 	res := <-wcli.cli.ResChannel
 
-	return res.text, nil
+	return res.text
+}
+
+func isPrintable(data []byte) bool {
+	for _, r := range string(data) {
+		if r == utf8.RuneError || (r < ' ' && r != '\n' && r != '\t') {
+			return false
+		}
+	}
+
+	return true
 }
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Version of MetaMask's eth_getEncryptionPublicKey / eth_decrypt format
+const EncryptionVersionX25519 = "x25519-xsalsa20-poly1305"
+
+// Payload of MetaMask's eth_decrypt. Fields are base64-encoded,
+// eth_decrypt takes the hex-encoded JSON of it.
+type EncryptedData struct {
+	Version        string `json:"version"`
+	Nonce          string `json:"nonce"`
+	EphemPublicKey string `json:"ephemPublicKey"`
+	Ciphertext     string `json:"ciphertext"`
+}
+
+// Encrypts to a secp256k1 public key with ECIES, same as geth's crypto/ecies
+// with no shared info. Decrypted with WalletKeeper.Decrypt.
+func Encrypt(pubKey *ecdsa.PublicKey, plaintext []byte) ([]byte, error) {
+	return ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pubKey), plaintext, nil, nil)
+}
+
+// Encrypts to a base64 x25519 public key as returned by
+// eth_getEncryptionPublicKey, same as MetaMask's eth-sig-util.
+func EncryptX25519(encryptionPublicKey string, plaintext []byte) (*EncryptedData, error) {
+	peerKey, err := decodeX25519Key(encryptionPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemPublicKey, ephemPrivateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+
+	ciphertext := box.Seal(nil, plaintext, &nonce, peerKey, ephemPrivateKey)
+
+	return &EncryptedData{
+		Version:        EncryptionVersionX25519,
+		Nonce:          base64.StdEncoding.EncodeToString(nonce[:]),
+		EphemPublicKey: base64.StdEncoding.EncodeToString(ephemPublicKey[:]),
+		Ciphertext:     base64.StdEncoding.EncodeToString(ciphertext),
+	}, nil
+}
+
+func decodeX25519Key(encoded string) (*[32]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data) != 32 {
+		return nil, errors.New("invalid x25519 public key")
+	}
+
+	var key [32]byte
+	copy(key[:], data)
+
+	return &key, nil
+}
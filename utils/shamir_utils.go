@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// Shamir's secret sharing over GF(256), using the AES field polynomial
+// x^8 + x^4 + x^3 + x + 1. Every share is [x || y_1 ... y_len(secret)].
+
+var gfExp [510]byte
+var gfLog [256]byte
+
+func init() {
+	var x byte = 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfExp[i+255] = x
+		gfLog[x] = byte(i)
+
+		// Multiply by the generator 3, i.e. x * 2 + x
+		x2 := x << 1
+		if x&0x80 != 0 {
+			x2 ^= 0x1b
+		}
+		x = x2 ^ x
+	}
+}
+
+func gfMul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a byte, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// Splits the secret into n shares, any threshold of which recover it
+func SplitSecret(secret []byte, n int, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("empty secret")
+	}
+	if threshold < 2 || n < threshold || n > 255 {
+		return nil, errors.New("shares must satisfy 2 <= threshold <= n <= 255")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+	for b, secretByte := range secret {
+		coefficients[0] = secretByte
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+
+		for _, share := range shares {
+			// Horner's method
+			var y byte
+			for c := threshold - 1; c >= 0; c-- {
+				y = gfMul(y, share[0]) ^ coefficients[c]
+			}
+			share[b+1] = y
+		}
+	}
+
+	for i := range coefficients {
+		coefficients[i] = 0
+	}
+
+	return shares, nil
+}
+
+// Recovers the secret from shares made by SplitSecret. The result is only
+// correct if at least threshold distinct shares are given.
+func CombineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("not enough shares")
+	}
+
+	length := len(shares[0])
+	seen := make(map[byte]bool, len(shares))
+
+	for _, share := range shares {
+		if len(share) != length || length < 2 {
+			return nil, errors.New("inconsistent share lengths")
+		}
+		if share[0] == 0 || seen[share[0]] {
+			return nil, errors.New("duplicate or invalid share index")
+		}
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, length-1)
+
+	// Lagrange interpolation at x = 0, where subtraction is XOR
+	for i, share := range shares {
+		var basis byte = 1
+		for j, other := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(other[0], other[0]^share[0]))
+			}
+		}
+
+		for b := range secret {
+			secret[b] ^= gfMul(share[b+1], basis)
+		}
+	}
+
+	return secret, nil
+}
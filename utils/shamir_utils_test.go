@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSplitSecretRoundTrip(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+
+	shares, err := SplitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("got %d shares, want 5", len(shares))
+	}
+
+	// Every subset of threshold shares recovers the secret
+	for i := 0; i < len(shares); i++ {
+		for j := i + 1; j < len(shares); j++ {
+			for k := j + 1; k < len(shares); k++ {
+				combined, err := CombineShares([][]byte{shares[k], shares[i], shares[j]})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(combined, secret) {
+					t.Fatalf("shares %d, %d, %d recovered %x", i, j, k, combined)
+				}
+			}
+		}
+	}
+
+	combined, err := CombineShares(shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(combined, secret) {
+		t.Fatalf("all shares recovered %x", combined)
+	}
+
+	// Fewer than threshold shares don't
+	combined, err = CombineShares(shares[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(combined, secret) {
+		t.Fatal("recovered the secret below the threshold")
+	}
+}
+
+func TestSplitSecretInvalid(t *testing.T) {
+	if _, err := SplitSecret(nil, 3, 2); err == nil {
+		t.Fatal("split an empty secret")
+	}
+	if _, err := SplitSecret([]byte{1}, 2, 3); err == nil {
+		t.Fatal("split with threshold above n")
+	}
+	if _, err := SplitSecret([]byte{1}, 3, 1); err == nil {
+		t.Fatal("split with threshold 1")
+	}
+}
+
+func TestCombineSharesInvalid(t *testing.T) {
+	shares, err := SplitSecret([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CombineShares(shares[:1]); err == nil {
+		t.Fatal("combined a single share")
+	}
+	if _, err := CombineShares([][]byte{shares[0], shares[0]}); err == nil {
+		t.Fatal("combined duplicate shares")
+	}
+	if _, err := CombineShares([][]byte{shares[0], shares[1][:3]}); err == nil {
+		t.Fatal("combined shares of different lengths")
+	}
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Parses EIP-712 typed data in the eth_signTypedData_v4 JSON format.
+// JSON numbers are accepted anywhere, including the domain chain ID,
+// and are kept lossless.
+func ParseTypedData(typedDataJSON []byte) (*apitypes.TypedData, error) {
+	decoder := json.NewDecoder(bytes.NewReader(typedDataJSON))
+	decoder.UseNumber()
+
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	normalized, err := json.Marshal(numbersToStrings(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	typedData := new(apitypes.TypedData)
+	if err := json.Unmarshal(normalized, typedData); err != nil {
+		return nil, err
+	}
+
+	return typedData, nil
+}
+
+func TypedDataHash(typedDataJSON []byte) ([]byte, error) {
+	typedData, err := ParseTypedData(typedDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, _, err := apitypes.TypedDataAndHash(*typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return hash, nil
+}
+
+func RecoverTypedDataSigner(typedDataJSON []byte, signature []byte) (common.Address, error) {
+	hash, err := TypedDataHash(typedDataJSON)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return RecoverHashSigner(hash, signature)
+}
+
+func VerifyTypedDataSignature(typedDataJSON []byte, signature []byte, signer common.Address) (bool, error) {
+	recovered, err := RecoverTypedDataSigner(typedDataJSON, signature)
+	if err != nil {
+		return false, err
+	}
+
+	return recovered == signer, nil
+}
+
+// Recovers the signer of an EIP-191 personal message (personal_sign)
+func RecoverMessageSigner(message []byte, signature []byte) (common.Address, error) {
+	return RecoverHashSigner(accounts.TextHash(message), signature)
+}
+
+func VerifyMessageSignature(message []byte, signature []byte, signer common.Address) (bool, error) {
+	recovered, err := RecoverMessageSigner(message, signature)
+	if err != nil {
+		return false, err
+	}
+
+	return recovered == signer, nil
+}
+
+// Recovers the address behind a 65-byte [R || S || V] signature.
+// Both V in {0, 1} and the Ethereum convention of {27, 28} are accepted.
+func RecoverHashSigner(hash []byte, signature []byte) (common.Address, error) {
+	if len(signature) != crypto.SignatureLength {
+		return common.Address{}, errors.New("invalid signature length")
+	}
+
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig, signature)
+
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+func numbersToStrings(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		return v.String()
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = numbersToStrings(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = numbersToStrings(item)
+		}
+	}
+
+	return value
+}
@@ -28,3 +28,38 @@ func EncodeTransaction(
 
 	return tx, err
 }
+
+// Anything that signs transactions for an account, e.g. wallet.Signer
+type TransactionSigner interface {
+	SignTx(account common.Address, tx *types.Transaction, chainId *big.Int) (*types.Transaction, error)
+}
+
+func EncodeSignedTransaction(
+	client *client.Client,
+	signer TransactionSigner,
+	from common.Address,
+	to common.Address,
+	value *big.Int,
+	calldata []byte,
+	gasMultiplier float64,
+) (*types.Transaction, error) {
+	tx, err := EncodeTransaction(client, from, to, value, calldata, gasMultiplier)
+	if err != nil {
+		return nil, err
+	}
+
+	// The transaction is never sent on failure, so its nonce is free again
+	chainId, err := client.ChainID()
+	if err != nil {
+		client.TransactionDone(from, tx, err)
+		return nil, err
+	}
+
+	signedTx, err := signer.SignTx(from, tx, chainId)
+	if err != nil {
+		client.TransactionDone(from, tx, err)
+		return nil, err
+	}
+
+	return signedTx, nil
+}
@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// Returns all signer and watch-only accounts with their metadata, oldest first
+func (wk *WalletKeeper) ListAccounts() ([]AccountInfo, error) {
+	addresses, err := wk.signer.Accounts()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*AccountInfo, 0, len(addresses))
+	signers := make(map[gethcommon.Address]bool, len(addresses))
+	for _, address := range addresses {
+		info, _ := wk.metadata.get(address)
+		info.WatchOnly = false
+		infos = append(infos, &info)
+		signers[address] = true
+	}
+	for _, info := range wk.metadata.watchOnly() {
+		if !signers[info.Address] {
+			infos = append(infos, info)
+		}
+	}
+	sortAccountInfos(infos)
+
+	result := make([]AccountInfo, len(infos))
+	for i, info := range infos {
+		result[i] = *info
+	}
+
+	return result, nil
+}
+
+func (wk *WalletKeeper) Account(address gethcommon.Address) (AccountInfo, error) {
+	if err := wk.checkAccount(address); err != nil {
+		return AccountInfo{}, err
+	}
+
+	info, _ := wk.metadata.get(address)
+	return info, nil
+}
+
+func (wk *WalletKeeper) SetLabel(address gethcommon.Address, label string) error {
+	if err := wk.checkAccount(address); err != nil {
+		return err
+	}
+
+	return wk.metadata.update(address, func(info *AccountInfo) {
+		info.Label = label
+	})
+}
+
+func (wk *WalletKeeper) SetTags(address gethcommon.Address, tags []string) error {
+	if err := wk.checkAccount(address); err != nil {
+		return err
+	}
+
+	return wk.metadata.update(address, func(info *AccountInfo) {
+		info.Tags = append([]string(nil), tags...)
+	})
+}
+
+// Like checkSigner, but watch-only accounts are fine too
+func (wk *WalletKeeper) checkAccount(address gethcommon.Address) error {
+	if err := wk.checkSigner(address); err != WatchOnlyAccount {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package wallet
+
+import (
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Signing request to be confirmed by the user, e.g. one coming from a dapp
+// through the JSON-RPC server, or a batch of transactions
+type ApprovalRequest struct {
+	// JSON-RPC method, e.g. eth_sendTransaction or personal_sign,
+	// or BatchSigningMethod for SignTransactions
+	Method string
+
+	// Where the request comes from, e.g. a dapp origin or a remote address
+	Origin string
+
+	Account gethcommon.Address
+	ChainID *big.Int
+
+	// Transactions to be signed, for transaction requests
+	Transactions []*types.Transaction
+
+	// Message or typed data JSON, for data signing requests
+	Data []byte
+}
+
+// Asks the user to confirm the request through the WalletUI.
+// Returns RequestRejected if it wasn't confirmed.
+func (wk *WalletKeeper) Approve(request *ApprovalRequest) error {
+	approved, err := wk.ui.ApproveRequest(request)
+	if err != nil {
+		return err
+	}
+
+	if !approved {
+		return RequestRejected
+	}
+
+	return nil
+}
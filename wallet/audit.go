@@ -0,0 +1,415 @@
+package wallet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"path/filepath"
+	"sync"
+	"time"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type AuditKind string
+
+const (
+	AuditKindTransaction AuditKind = "transaction"
+	AuditKindMessage     AuditKind = "message"
+	AuditKindTypedData   AuditKind = "typedData"
+	AuditKindHash        AuditKind = "hash"
+)
+
+// One signing request. Records are kept whether the signature was
+// produced or not, failures have Error set.
+type AuditRecord struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Kind AuditKind `json:"kind"`
+
+	Signer     gethcommon.Address `json:"signer"`
+	Autosigned bool               `json:"autosigned"`
+
+	// Transactions only. TxHash is the hash of the signed transaction.
+	ChainID  *big.Int            `json:"chainId,omitempty"`
+	TxHash   *gethcommon.Hash    `json:"txHash,omitempty"`
+	To       *gethcommon.Address `json:"to,omitempty"`
+	Value    *big.Int            `json:"value,omitempty"`
+	Selector hexutil.Bytes       `json:"selector,omitempty"`
+
+	// Keccak256 of the signed message, typed data JSON or the raw hash itself
+	DataHash *gethcommon.Hash `json:"dataHash,omitempty"`
+
+	Error string `json:"error,omitempty"`
+
+	// Hash of the previous record, zero for the first one
+	PrevHash gethcommon.Hash `json:"prevHash"`
+}
+
+// Last record of the log. Keeping a copy of it somewhere else,
+// e.g. in a compliance ticket, pins everything logged up to it.
+type AuditHead struct {
+	Seq  uint64          `json:"seq"`
+	Hash gethcommon.Hash `json:"hash"`
+}
+
+// A line of the log. Hash is keccak256 of the record JSON exactly as written,
+// so verification doesn't depend on re-encoding.
+type auditLine struct {
+	Record json.RawMessage `json:"record"`
+	Hash   gethcommon.Hash `json:"hash"`
+}
+
+// Append-only log of JSON lines, every record chained to the previous one
+// by hash. The head is mirrored to a sidecar file so that dropping records
+// from the end of the log is detected as well.
+type auditLog struct {
+	storage Storage
+	name    string
+
+	mu   sync.Mutex
+	head AuditHead
+}
+
+func loadAuditLog(storage Storage, name string) (*auditLog, error) {
+	log := &auditLog{storage: storage, name: name}
+
+	head, err := readAuditHead(storage, auditHeadName(name))
+	if err != nil {
+		return nil, err
+	}
+	log.head = head
+
+	if err := repairAuditLog(storage, name); err != nil {
+		return nil, err
+	}
+
+	// A crash between appending a record and writing the head
+	// leaves the log exactly one record ahead
+	last, err := readLastAuditRecord(storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil && last.record.Seq == head.Seq+1 && last.record.PrevHash == head.Hash {
+		if err := log.writeHead(AuditHead{last.record.Seq, last.hash}); err != nil {
+			return nil, err
+		}
+	}
+
+	return log, nil
+}
+
+// Path of the audit log on disk, empty if the wallet's Storage isn't on disk
+func (wk *WalletKeeper) AuditLogPath() string {
+	if storage, ok := wk.audit.storage.(*DirectoryStorage); ok {
+		return storage.Path(wk.audit.name)
+	}
+
+	return ""
+}
+
+func (wk *WalletKeeper) AuditHead() AuditHead {
+	wk.audit.mu.Lock()
+	defer wk.audit.mu.Unlock()
+
+	return wk.audit.head
+}
+
+// Verifies the wallet's own audit log, see VerifyAuditLog
+func (wk *WalletKeeper) VerifyAuditLog(anchor *AuditHead) ([]AuditRecord, error) {
+	wk.audit.mu.Lock()
+	defer wk.audit.mu.Unlock()
+
+	return verifyAuditLog(wk.audit.storage, wk.audit.name, anchor)
+}
+
+// Checks the hash chain of the log at path and returns its records.
+// The last record has to match the head sidecar file, which detects
+// truncation. If anchor is given, e.g. a head noted at a previous review,
+// the log must still contain that record unchanged, which detects
+// the log and its head being rewritten together.
+// Any mismatch is reported as InvalidAuditLog.
+func VerifyAuditLog(path string, anchor *AuditHead) ([]AuditRecord, error) {
+	return verifyAuditLog(NewDirectoryStorage(filepath.Dir(path)), filepath.Base(path), anchor)
+}
+
+func verifyAuditLog(storage Storage, name string, anchor *AuditHead) ([]AuditRecord, error) {
+	head, err := readAuditHead(storage, auditHeadName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	// A missing log is fine as long as the head says there are no records
+	data, err := storage.ReadFile(name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, FileSystemAccess
+	}
+
+	var records []AuditRecord
+	var last AuditHead
+
+	if err == nil {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 1<<20)
+
+		for scanner.Scan() {
+			var line auditLine
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				return records, fmt.Errorf("%w: malformed record after #%d", InvalidAuditLog, last.Seq)
+			}
+
+			var record AuditRecord
+			if err := json.Unmarshal(line.Record, &record); err != nil {
+				return records, fmt.Errorf("%w: malformed record after #%d", InvalidAuditLog, last.Seq)
+			}
+
+			switch {
+			case gethcommon.BytesToHash(crypto.Keccak256(line.Record)) != line.Hash:
+				return records, fmt.Errorf("%w: record #%d was modified", InvalidAuditLog, record.Seq)
+			case record.Seq != last.Seq+1:
+				return records, fmt.Errorf("%w: record #%d follows #%d", InvalidAuditLog, record.Seq, last.Seq)
+			case record.PrevHash != last.Hash:
+				return records, fmt.Errorf("%w: record #%d is not chained to #%d", InvalidAuditLog, record.Seq, last.Seq)
+			case anchor != nil && record.Seq == anchor.Seq && line.Hash != anchor.Hash:
+				return records, fmt.Errorf("%w: record #%d doesn't match the anchor", InvalidAuditLog, record.Seq)
+			}
+
+			records = append(records, record)
+			last = AuditHead{record.Seq, line.Hash}
+		}
+		if err := scanner.Err(); err != nil {
+			return records, FileSystemAccess
+		}
+	}
+
+	if last != head {
+		return records, fmt.Errorf(
+			"%w: log ends at #%d but its head is #%d, records were removed or added",
+			InvalidAuditLog, last.Seq, head.Seq,
+		)
+	}
+	if anchor != nil && anchor.Seq > last.Seq {
+		return records, fmt.Errorf("%w: anchored record #%d is missing", InvalidAuditLog, anchor.Seq)
+	}
+
+	return records, nil
+}
+
+// Fills in the sequence number, time and chaining, and makes the record durable
+func (log *auditLog) append(record AuditRecord) error {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+
+	record.Seq = log.head.Seq + 1
+	record.Time = time.Now().UTC()
+	record.PrevHash = log.head.Hash
+
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	line := auditLine{recordJSON, gethcommon.BytesToHash(crypto.Keccak256(recordJSON))}
+	lineJSON, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	if err := log.storage.AppendFile(log.name, append(lineJSON, '\n')); err != nil {
+		return AuditLogFailed
+	}
+
+	// The record is in the log now, so the next one is chained to it
+	// even if the head can't be written. The head is written again then,
+	// or reconciled on the next load.
+	log.head = AuditHead{record.Seq, line.Hash}
+
+	return log.writeHead(log.head)
+}
+
+// Must be called with the lock held
+func (log *auditLog) writeHead(head AuditHead) error {
+	headJSON, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	if err := log.storage.WriteFile(auditHeadName(log.name), headJSON); err != nil {
+		return AuditLogFailed
+	}
+	log.head = head
+
+	return nil
+}
+
+// Logs a transaction signing request. The signing result is passed through,
+// unless the record can't be written, since an unlogged signature
+// must not leave the wallet.
+func (wk *WalletKeeper) auditTransaction(
+	chainId *big.Int,
+	tx *types.Transaction,
+	signer gethcommon.Address,
+	autosign bool,
+	signedTx *types.Transaction,
+	signErr error,
+) (*types.Transaction, error) {
+	record := AuditRecord{
+		Kind:       AuditKindTransaction,
+		Signer:     signer,
+		Autosigned: autosign,
+		ChainID:    chainId,
+		To:         tx.To(),
+		Value:      tx.Value(),
+	}
+	if data := tx.Data(); len(data) >= 4 {
+		record.Selector = data[:4]
+	}
+	if signedTx != nil {
+		txHash := signedTx.Hash()
+		record.TxHash = &txHash
+	}
+	if signErr != nil {
+		record.Error = signErr.Error()
+	}
+
+	if err := wk.audit.append(record); err != nil {
+		fmt.Println(err)
+		return nil, AuditLogFailed
+	}
+
+	return signedTx, signErr
+}
+
+// Same as auditTransaction for messages, typed data and hashes
+func (wk *WalletKeeper) auditData(
+	kind AuditKind,
+	data []byte,
+	signer gethcommon.Address,
+	autosign bool,
+	signature []byte,
+	signErr error,
+) ([]byte, error) {
+	dataHash := crypto.Keccak256Hash(data)
+	if kind == AuditKindHash {
+		dataHash = gethcommon.BytesToHash(data)
+	}
+
+	record := AuditRecord{
+		Kind:       kind,
+		Signer:     signer,
+		Autosigned: autosign,
+		DataHash:   &dataHash,
+	}
+	if signErr != nil {
+		record.Error = signErr.Error()
+	}
+
+	if err := wk.audit.append(record); err != nil {
+		fmt.Println(err)
+		return nil, AuditLogFailed
+	}
+
+	return signature, signErr
+}
+
+type auditLineRecord struct {
+	record AuditRecord
+	hash   gethcommon.Hash
+}
+
+// Last record of the log if it's intact, nil otherwise
+func readLastAuditRecord(storage Storage, name string) (*auditLineRecord, error) {
+	data, err := storage.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, FileSystemAccess
+	}
+
+	data = bytes.TrimRight(data, "\n")
+
+	return parseAuditLine(data[bytes.LastIndexByte(data, '\n')+1:]), nil
+}
+
+// Record of the line if it's intact, nil otherwise
+func parseAuditLine(lineJSON []byte) *auditLineRecord {
+	var line auditLine
+	if err := json.Unmarshal(lineJSON, &line); err != nil {
+		return nil
+	}
+
+	var record AuditRecord
+	if err := json.Unmarshal(line.Record, &record); err != nil {
+		return nil
+	}
+	if gethcommon.BytesToHash(crypto.Keccak256(line.Record)) != line.Hash {
+		return nil
+	}
+
+	return &auditLineRecord{record, line.Hash}
+}
+
+// A crash while appending can leave a partial last line, which the next
+// record would be appended to. Such a line, or a last line that doesn't
+// parse, is moved to a quarantine file next to the log. Dropping it can't
+// hide a record: the head still points at it, so verification fails.
+func repairAuditLog(storage Storage, name string) error {
+	data, err := storage.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return FileSystemAccess
+	}
+
+	complete := len(data) > 0 && data[len(data)-1] == '\n'
+	start := bytes.LastIndexByte(bytes.TrimSuffix(data, []byte("\n")), '\n') + 1
+	if len(data) == 0 || (complete && parseAuditLine(data[start:len(data)-1]) != nil) {
+		return nil
+	}
+
+	partial := bytes.TrimSuffix(data[start:], []byte("\n"))
+	fmt.Println("Moving a partial audit log record to", auditQuarantineName(name))
+
+	if err := storage.AppendFile(auditQuarantineName(name), append(partial, '\n')); err != nil {
+		return AuditLogFailed
+	}
+	if err := storage.WriteFile(name, data[:start]); err != nil {
+		return AuditLogFailed
+	}
+
+	return nil
+}
+
+func auditQuarantineName(name string) string {
+	return name + ".partial"
+}
+
+func auditHeadName(name string) string {
+	return name + ".head"
+}
+
+func readAuditHead(storage Storage, name string) (AuditHead, error) {
+	var head AuditHead
+
+	data, err := storage.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return head, nil
+	}
+	if err != nil {
+		return head, FileSystemAccess
+	}
+
+	if err := json.Unmarshal(bytes.TrimSpace(data), &head); err != nil {
+		return head, fmt.Errorf("%w: malformed head file", InvalidAuditLog)
+	}
+
+	return head, nil
+}
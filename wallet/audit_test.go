@@ -0,0 +1,207 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Wallet with an audit log of three records
+func newTestAuditWallet(t *testing.T) *WalletKeeper {
+	t.Helper()
+
+	wk, address := newTestWallet(t)
+
+	if _, err := wk.SignTransaction(big.NewInt(1), testTransaction(testRecipient, 3, []byte{1, 2, 3, 4, 5}), address, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wk.SignTransaction(big.NewInt(1), testTransaction(testRecipient, 1, nil), address, true); err != AccountLocked {
+		t.Fatalf("err = %v, want AccountLocked", err)
+	}
+	if _, err := wk.SignMessage([]byte("hello"), address, false); err != nil {
+		t.Fatal(err)
+	}
+
+	return wk
+}
+
+func reopenTestWallet(t *testing.T, wk *WalletKeeper) *WalletKeeper {
+	t.Helper()
+
+	reopened, err := NewWalletKeeperWithOptions(testUI{}, Options{Path: wk.Path(), LightScrypt: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return reopened
+}
+
+func TestAuditLogRecords(t *testing.T) {
+	wk := newTestAuditWallet(t)
+
+	records, err := wk.VerifyAuditLog(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+
+	tx := records[0]
+	if tx.Kind != AuditKindTransaction || tx.TxHash == nil || tx.Value.Int64() != 3 || !bytes.Equal(tx.Selector, []byte{1, 2, 3, 4}) {
+		t.Fatalf("transaction record = %+v", tx)
+	}
+	if records[1].Error == "" || !records[1].Autosigned || records[1].TxHash != nil {
+		t.Fatalf("failed record = %+v", records[1])
+	}
+	if records[2].Kind != AuditKindMessage || records[2].DataHash == nil {
+		t.Fatalf("message record = %+v", records[2])
+	}
+
+	if head := wk.AuditHead(); head.Seq != 3 {
+		t.Fatalf("head = %+v", head)
+	}
+	if _, err := VerifyAuditLog(wk.AuditLogPath(), nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditLogTampering(t *testing.T) {
+	wk := newTestAuditWallet(t)
+	anchor := wk.AuditHead()
+
+	path := wk.AuditLogPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+
+	tests := map[string]string{
+		"edited":    strings.Replace(string(data), `"value":3`, `"value":4`, 1),
+		"truncated": lines[0] + lines[1],
+		"removed":   lines[0] + lines[2],
+		"reordered": lines[1] + lines[0] + lines[2],
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := wk.VerifyAuditLog(nil); !errors.Is(err, InvalidAuditLog) {
+				t.Fatalf("err = %v, want InvalidAuditLog", err)
+			}
+		})
+	}
+
+	// Rewriting the log and its head together is caught by the anchor
+	if err := os.WriteFile(path, []byte(lines[0]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	first := parseAuditLine([]byte(strings.TrimSpace(lines[0])))
+	if err := os.WriteFile(path+".head", []byte(`{"seq":1,"hash":"`+first.hash.Hex()+`"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wk.VerifyAuditLog(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wk.VerifyAuditLog(&anchor); !errors.Is(err, InvalidAuditLog) {
+		t.Fatalf("err = %v, want InvalidAuditLog", err)
+	}
+}
+
+// A crash before the head is written leaves the log one record ahead
+func TestAuditLogHeadBehind(t *testing.T) {
+	wk := newTestAuditWallet(t)
+
+	path := wk.AuditLogPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+
+	first := parseAuditLine([]byte(strings.TrimSpace(lines[0])))
+	second := parseAuditLine([]byte(strings.TrimSpace(lines[1])))
+	if err := os.WriteFile(path, []byte(lines[0]+lines[1]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".head", []byte(`{"seq":1,"hash":"`+first.hash.Hex()+`"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := reopenTestWallet(t, wk)
+	if head := reopened.AuditHead(); head != (AuditHead{2, second.hash}) {
+		t.Fatalf("head = %+v, want #2", head)
+	}
+	if records, err := reopened.VerifyAuditLog(nil); err != nil || len(records) != 2 {
+		t.Fatalf("got %d records, err = %v", len(records), err)
+	}
+}
+
+// A crash while appending leaves a partial last line
+func TestAuditLogPartialRecord(t *testing.T) {
+	wk := newTestAuditWallet(t)
+	accounts, err := wk.ListAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := wk.AuditLogPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partial := `{"record":{"seq":4,"ti`
+	if err := os.WriteFile(path, append(data, partial...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := reopenTestWallet(t, wk)
+	if _, err := reopened.SignMessage([]byte("again"), accounts[0].Address, false); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := reopened.VerifyAuditLog(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d records, want 4", len(records))
+	}
+
+	quarantined, err := os.ReadFile(path + ".partial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(quarantined) != partial+"\n" {
+		t.Fatalf("quarantined %q", quarantined)
+	}
+}
+
+// Dropping a complete record that the head points at is still caught
+func TestAuditLogMalformedLastRecord(t *testing.T) {
+	wk := newTestAuditWallet(t)
+
+	path := wk.AuditLogPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	damaged := append(data[:len(data)-10:len(data)-10], "garbage}\n"...)
+	if err := os.WriteFile(path, damaged, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := reopenTestWallet(t, wk)
+	if _, err := reopened.VerifyAuditLog(nil); !errors.Is(err, InvalidAuditLog) {
+		t.Fatalf("err = %v, want InvalidAuditLog", err)
+	}
+}
@@ -0,0 +1,181 @@
+package wallet
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+const backupVersion = 1
+
+// Backup file on disk, the whole archive is encrypted with the backup passphrase
+type backupFile struct {
+	Version int                 `json:"version"`
+	Crypto  keystore.CryptoJSON `json:"crypto"`
+}
+
+// Key files are kept as is, i.e. still encrypted with their own passphrases
+type backupArchive struct {
+	CreatedAt time.Time         `json:"createdAt"`
+	Keys      []json.RawMessage `json:"keys"`
+	Accounts  []*AccountInfo    `json:"accounts"`
+	Seed      *seedFile         `json:"seed,omitempty"`
+}
+
+type RestoreReport struct {
+	Restored   []gethcommon.Address
+	Duplicates []gethcommon.Address
+	WatchOnly  []gethcommon.Address
+
+	// Set if the backup had an HD seed and it was restored.
+	// An existing seed is never replaced.
+	SeedRestored bool
+}
+
+// Writes all key files, account metadata including watch-only accounts
+// and the HD seed into one archive encrypted with passphrase
+func (wk *WalletKeeper) Backup(dest string, passphrase string) error {
+	archive := backupArchive{CreatedAt: time.Now().UTC()}
+
+	addresses, err := wk.ks.Accounts()
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		keyJSON, err := wk.ks.keyJSON(address)
+		if err != nil {
+			return err
+		}
+
+		info, _ := wk.metadata.get(address)
+
+		archive.Keys = append(archive.Keys, keyJSON)
+		archive.Accounts = append(archive.Accounts, &info)
+	}
+	archive.Accounts = append(archive.Accounts, wk.metadata.watchOnly()...)
+
+	hasSeed, err := wk.HasSeed()
+	if err != nil {
+		return err
+	}
+
+	if hasSeed {
+		seed, err := wk.readSeedFile()
+		if err != nil {
+			return err
+		}
+		archive.Seed = seed
+	}
+
+	archiveJSON, err := json.Marshal(archive)
+	if err != nil {
+		return err
+	}
+
+	cryptoJSON, err := keystore.EncryptDataV3(archiveJSON, []byte(passphrase), wk.opts.ScryptN, wk.opts.ScryptP)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(backupFile{backupVersion, cryptoJSON})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(dest, data)
+}
+
+// Merges a backup made with Backup into the wallet. Accounts that are
+// already in the keystore are skipped and reported as duplicates.
+func (wk *WalletKeeper) Restore(src string, passphrase string) (*RestoreReport, error) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, FileSystemAccess
+	}
+
+	var file backupFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, InvalidBackup
+	}
+	if file.Version != backupVersion {
+		return nil, InvalidBackup
+	}
+
+	archiveJSON, err := keystore.DecryptDataV3(file.Crypto, passphrase)
+	if err != nil {
+		return nil, UnauthorizedAccess
+	}
+
+	var archive backupArchive
+	if err := json.Unmarshal(archiveJSON, &archive); err != nil {
+		return nil, InvalidBackup
+	}
+
+	infos := make(map[gethcommon.Address]*AccountInfo)
+	for _, info := range archive.Accounts {
+		infos[info.Address] = info
+	}
+
+	report := new(RestoreReport)
+
+	for _, keyJSON := range archive.Keys {
+		address, err := keyFileAddress(keyJSON)
+		if err != nil {
+			return nil, InvalidBackup
+		}
+
+		if wk.ks.HasAddress(address) {
+			report.Duplicates = append(report.Duplicates, address)
+			continue
+		}
+
+		if err := wk.ks.importKeyJSON(address, keyJSON); err != nil {
+			return nil, err
+		}
+
+		if info, ok := infos[address]; ok {
+			err = wk.metadata.update(address, func(existing *AccountInfo) {
+				*existing = info.copy()
+			})
+		} else {
+			err = wk.metadata.add(address, "")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		report.Restored = append(report.Restored, address)
+	}
+
+	for _, info := range archive.Accounts {
+		if !info.WatchOnly || wk.ks.HasAddress(info.Address) || wk.IsWatchOnly(info.Address) {
+			continue
+		}
+
+		restored := info.copy()
+		if err := wk.metadata.update(info.Address, func(existing *AccountInfo) {
+			*existing = restored
+		}); err != nil {
+			return nil, err
+		}
+		report.WatchOnly = append(report.WatchOnly, info.Address)
+	}
+
+	hasSeed, err := wk.HasSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	if archive.Seed != nil && !hasSeed {
+		if err := wk.writeSeedFile(archive.Seed); err != nil {
+			return nil, err
+		}
+		report.SeedRestored = true
+	}
+
+	return report, nil
+}
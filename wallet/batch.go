@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"fmt"
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Name of batch requests in ApprovalRequest.Method
+const BatchSigningMethod = "SignTransactions"
+
+// Signs a batch of transactions after a single approval of its summary
+// through the WalletUI, and a single passphrase prompt without autosign.
+// With autosign the session must have a signature left for every
+// transaction. Either all transactions are signed or none: if any of them
+// fails the signing policy or can't be signed, no signature is returned.
+func (wk *WalletKeeper) SignTransactions(
+	chainId *big.Int,
+	txs []*types.Transaction,
+	signer gethcommon.Address,
+	autosign bool,
+) ([]*types.Transaction, error) {
+	if len(txs) == 0 {
+		return nil, nil
+	}
+
+	if err := wk.checkSigner(signer); err != nil {
+		return nil, err
+	}
+
+	// Unwinds policy reservations if the batch fails
+	var releases []func()
+	releaseAll := func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+
+	fail := func(err error) ([]*types.Transaction, error) {
+		releaseAll()
+
+		for _, tx := range txs {
+			if _, auditErr := wk.auditTransaction(chainId, tx, signer, autosign, nil, err); auditErr != nil {
+				return nil, auditErr
+			}
+		}
+
+		return nil, err
+	}
+
+	if policy := wk.SigningPolicy(); policy != nil {
+		for i, tx := range txs {
+			release, err := policy.authorize(chainId, tx, signer)
+			if err != nil {
+				return fail(fmt.Errorf("transaction #%d: %w", i+1, err))
+			}
+			releases = append(releases, release)
+		}
+	}
+
+	err := wk.Approve(&ApprovalRequest{
+		Method:       BatchSigningMethod,
+		Account:      signer,
+		ChainID:      chainId,
+		Transactions: txs,
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	lock, err := wk.authorizeBatch(signer, autosign, len(txs))
+	if err != nil {
+		return fail(err)
+	}
+	defer lock()
+
+	fmt.Println("Signing", len(txs), "transactions with address:", signer.Hex())
+	fmt.Println()
+
+	signedTxs := make([]*types.Transaction, len(txs))
+	for i, tx := range txs {
+		signedTx, err := wk.signer.SignTx(signer, tx, chainId)
+		if err != nil {
+			fmt.Println(err)
+			return fail(SigningFailed)
+		}
+		signedTxs[i] = signedTx
+	}
+
+	for i, tx := range txs {
+		if _, err := wk.auditTransaction(chainId, tx, signer, autosign, signedTxs[i], nil); err != nil {
+			releaseAll()
+			return nil, err
+		}
+	}
+
+	return signedTxs, nil
+}
+
+// Sum of the values of the transactions
+func (request *ApprovalRequest) TotalValue() *big.Int {
+	total := new(big.Int)
+	for _, tx := range request.Transactions {
+		total.Add(total, tx.Value())
+	}
+
+	return total
+}
+
+// Most the transactions may pay in fees: gas limit times max fee per gas
+// (gas price for legacy transactions)
+func (request *ApprovalRequest) TotalMaxFee() *big.Int {
+	total := new(big.Int)
+	for _, tx := range request.Transactions {
+		total.Add(total, new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas())))
+	}
+
+	return total
+}
+
+// Same as authorize, for count signatures at once
+func (wk *WalletKeeper) authorizeBatch(address gethcommon.Address, autosign bool, count int) (func(), error) {
+	if autosign && !wk.signerApproves() {
+		if err := wk.sessions.useN(address, count); err != nil {
+			return nil, err
+		}
+
+		return func() { wk.sessions.release(address) }, nil
+	}
+
+	return wk.authorize(address, autosign)
+}
@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+
+	"github.com/0xNSHuman/dapp-tools/common"
+	"github.com/0xNSHuman/dapp-tools/utils"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/nacl/box"
+)
+
+// Decrypts an ECIES ciphertext made for the account, e.g. by utils.Encrypt
+// or geth's crypto/ecies. Asks for the passphrase through the WalletUI.
+func (wk *WalletKeeper) Decrypt(address gethcommon.Address, ciphertext []byte) ([]byte, error) {
+	return wk.withKey(address, func(privKey *ecdsa.PrivateKey) ([]byte, error) {
+		plaintext, err := ecies.ImportECDSA(privKey).Decrypt(ciphertext, nil, nil)
+		if err != nil {
+			return nil, DecryptionFailed
+		}
+
+		return plaintext, nil
+	})
+}
+
+// The account's x25519 public key in base64, same as MetaMask's
+// eth_getEncryptionPublicKey. Asks for the passphrase through the WalletUI.
+func (wk *WalletKeeper) EncryptionPublicKey(address gethcommon.Address) (string, error) {
+	publicKey, err := wk.withKey(address, func(privKey *ecdsa.PrivateKey) ([]byte, error) {
+		return curve25519.X25519(x25519PrivateKey(privKey)[:], curve25519.Basepoint)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(publicKey), nil
+}
+
+// Decrypts data made for EncryptionPublicKey, same as MetaMask's eth_decrypt.
+// Asks for the passphrase through the WalletUI.
+func (wk *WalletKeeper) DecryptX25519(address gethcommon.Address, data *utils.EncryptedData) ([]byte, error) {
+	if data.Version != utils.EncryptionVersionX25519 {
+		return nil, common.NotSupported
+	}
+
+	nonce, err1 := base64.StdEncoding.DecodeString(data.Nonce)
+	ephemPublicKey, err2 := base64.StdEncoding.DecodeString(data.EphemPublicKey)
+	ciphertext, err3 := base64.StdEncoding.DecodeString(data.Ciphertext)
+	if err1 != nil || err2 != nil || err3 != nil || len(nonce) != 24 || len(ephemPublicKey) != 32 {
+		return nil, DecryptionFailed
+	}
+
+	return wk.withKey(address, func(privKey *ecdsa.PrivateKey) ([]byte, error) {
+		plaintext, ok := box.Open(
+			nil,
+			ciphertext,
+			(*[24]byte)(nonce),
+			(*[32]byte)(ephemPublicKey),
+			x25519PrivateKey(privKey),
+		)
+		if !ok {
+			return nil, DecryptionFailed
+		}
+
+		return plaintext, nil
+	})
+}
+
+// Unlocks the account for a single use of its key.
+// External signers don't give out keys, so they aren't supported.
+func (wk *WalletKeeper) withKey(
+	address gethcommon.Address,
+	use func(privKey *ecdsa.PrivateKey) ([]byte, error),
+) ([]byte, error) {
+	if wk.opts.Signer != nil {
+		return nil, common.NotSupported
+	}
+
+	if err := wk.checkSigner(address); err != nil {
+		return nil, err
+	}
+
+	lock, err := wk.authorize(address, false)
+	if err != nil {
+		return nil, err
+	}
+	defer lock()
+
+	return wk.ks.withKey(address, use)
+}
+
+// MetaMask uses the secp256k1 key bytes as the x25519 secret key as is
+func x25519PrivateKey(privKey *ecdsa.PrivateKey) *[32]byte {
+	var key [32]byte
+	privKey.D.FillBytes(key[:])
+
+	return &key
+}
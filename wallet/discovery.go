@@ -0,0 +1,198 @@
+package wallet
+
+import (
+	"context"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/0xNSHuman/dapp-tools/client"
+	"github.com/ethereum/go-ethereum/accounts"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const DefaultGapLimit = 20
+
+// Derivation path with an {index} placeholder
+type PathTemplate string
+
+const (
+	// m/44'/60'/0'/0/i, used by MetaMask, Trezor and this wallet
+	PathTemplateStandard PathTemplate = "m/44'/60'/0'/0/{index}"
+
+	// m/44'/60'/i'/0/0, one account per BIP-44 account level
+	PathTemplateLedgerLive PathTemplate = "m/44'/60'/{index}'/0/0"
+
+	// m/44'/60'/0'/i, used by MyEtherWallet and the legacy Ledger app
+	PathTemplateLegacyMEW PathTemplate = "m/44'/60'/0'/{index}"
+)
+
+func (t PathTemplate) Path(index uint32) (accounts.DerivationPath, error) {
+	if !strings.Contains(string(t), "{index}") {
+		return nil, InvalidDerivationPath
+	}
+
+	path, err := accounts.ParseDerivationPath(strings.ReplaceAll(string(t), "{index}", strconv.FormatUint(uint64(index), 10)))
+	if err != nil {
+		return nil, InvalidDerivationPath
+	}
+
+	return path, nil
+}
+
+type DiscoveryOptions struct {
+	// Templates to scan, all the predefined ones if empty
+	Templates []PathTemplate
+
+	// Number of unused addresses in a row after which a template
+	// is done. Defaults to DefaultGapLimit.
+	GapLimit int
+}
+
+type DiscoveryReport struct {
+	// Used accounts in the order they were found
+	Accounts  []DiscoveredAccount
+	Templates []TemplateReport
+}
+
+type DiscoveredAccount struct {
+	Address  gethcommon.Address
+	Template PathTemplate
+	Path     string
+	Index    uint32
+	Nonce    uint64
+	Balance  *big.Int
+
+	// False if the account was in the wallet already
+	Imported bool
+}
+
+type TemplateReport struct {
+	Template PathTemplate
+	Scanned  int
+	Used     int
+
+	// Sum of the balances of the used accounts
+	Balance *big.Int
+}
+
+// Scans addresses derived from the stored HD seed, the way hardware wallets
+// do: an address is used if it has sent a transaction or holds a balance.
+// Every used account is added to the wallet, encrypted with passphrase.
+// A template is scanned until GapLimit unused addresses in a row.
+func (wk *WalletKeeper) DiscoverAccounts(
+	client *client.Client,
+	passphrase string,
+	opts DiscoveryOptions,
+) (*DiscoveryReport, error) {
+	templates := opts.Templates
+	if len(templates) == 0 {
+		templates = []PathTemplate{PathTemplateStandard, PathTemplateLedgerLive, PathTemplateLegacyMEW}
+	}
+
+	gapLimit := opts.GapLimit
+	if gapLimit <= 0 {
+		gapLimit = DefaultGapLimit
+	}
+
+	file, err := wk.readSeedFile()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := decryptSeed(file, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	seed, err := seedFromMnemonic(secret.Mnemonic, secret.SeedPassphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	report := new(DiscoveryReport)
+	nextIndex := file.NextIndex
+
+	// Templates may share paths, e.g. index 0 of the standard and Ledger Live
+	// ones, such accounts are reported once
+	found := make(map[gethcommon.Address]bool)
+
+	for _, template := range templates {
+		templateReport := TemplateReport{Template: template, Balance: new(big.Int)}
+
+		for index, gap := uint32(0), 0; gap < gapLimit; index++ {
+			path, err := template.Path(index)
+			if err != nil {
+				return nil, err
+			}
+
+			privKey, err := derivePrivateKey(seed, path)
+			if err != nil {
+				return nil, err
+			}
+
+			address := crypto.PubkeyToAddress(privKey.PublicKey)
+
+			nonce, err := client.EthClient.NonceAt(context.Background(), address, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			balance, err := client.EthClient.BalanceAt(context.Background(), address, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			templateReport.Scanned++
+
+			if nonce == 0 && balance.Sign() == 0 {
+				gap++
+				continue
+			}
+			gap = 0
+
+			templateReport.Used++
+			templateReport.Balance.Add(templateReport.Balance, balance)
+
+			if found[address] {
+				continue
+			}
+			found[address] = true
+
+			account := DiscoveredAccount{
+				Address:  address,
+				Template: template,
+				Path:     path.String(),
+				Index:    index,
+				Nonce:    nonce,
+				Balance:  balance,
+			}
+
+			if !wk.ks.HasAddress(address) {
+				if _, err := wk.importKey(privKey, passphrase, path.String()); err != nil {
+					return nil, err
+				}
+				account.Imported = true
+			}
+
+			if template == PathTemplateStandard && index >= nextIndex {
+				nextIndex = index + 1
+			}
+
+			report.Accounts = append(report.Accounts, account)
+		}
+
+		report.Templates = append(report.Templates, templateReport)
+	}
+
+	// CreateWallet continues after the last used standard account
+	if nextIndex != file.NextIndex {
+		file.NextIndex = nextIndex
+		if err := wk.writeSeedFile(file); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
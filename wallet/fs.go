@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Writes data to a temp file next to the target and renames it over,
+// so readers never see a partially written file. Both the file and the
+// rename are synced to disk before returning, so a power loss leaves
+// either the old or the new file.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return FileSystemAccess
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return FileSystemAccess
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return FileSystemAccess
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return FileSystemAccess
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return FileSystemAccess
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return FileSystemAccess
+	}
+
+	return syncDir(dir)
+}
+
+// Makes renames and new files in dir durable. Windows can't sync directories
+// and doesn't need to, renames there are durable once they return.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return FileSystemAccess
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return FileSystemAccess
+	}
+
+	return nil
+}
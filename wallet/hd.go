@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip32"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// 128 bits of entropy produce a 12-word mnemonic, same as MetaMask
+const mnemonicEntropyBits = 128
+
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", err
+	}
+
+	return bip39.NewMnemonic(entropy)
+}
+
+func NormalizeMnemonic(mnemonic string) string {
+	return strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+}
+
+func ValidateMnemonic(mnemonic string) error {
+	if _, err := bip39.EntropyFromMnemonic(NormalizeMnemonic(mnemonic)); err != nil {
+		return InvalidSeedPhrase
+	}
+
+	return nil
+}
+
+// Returns m/44'/60'/0'/0/index
+func DerivationPath(index uint32) accounts.DerivationPath {
+	path := make(accounts.DerivationPath, len(accounts.DefaultRootDerivationPath), len(accounts.DefaultRootDerivationPath)+1)
+	copy(path, accounts.DefaultRootDerivationPath)
+
+	return append(path, index)
+}
+
+func seedFromMnemonic(mnemonic string, seedPassphrase string) ([]byte, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(NormalizeMnemonic(mnemonic), seedPassphrase)
+	if err != nil {
+		return nil, InvalidSeedPhrase
+	}
+
+	return seed, nil
+}
+
+func derivePrivateKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	key, err := bip32.NewMasterKey(seed)
+	if err != nil {
+		return nil, InvalidSeedPhrase
+	}
+
+	for _, childIndex := range path {
+		key, err = key.NewChildKey(childIndex)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	privKey, err := crypto.ToECDSA(key.Key)
+	if err != nil {
+		return nil, InvalidPrivateKey
+	}
+
+	return privKey, nil
+}
@@ -0,0 +1,197 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+type ImportReport struct {
+	Imported   []gethcommon.Address
+	Duplicates []gethcommon.Address
+
+	// Files that aren't key files
+	Skipped []string
+}
+
+// Imports a V3 keystore file, e.g. one exported from geth or MyEtherWallet.
+// The file is kept as is if passphrase is the same as filePassphrase,
+// otherwise the key is re-encrypted with passphrase.
+func (wk *WalletKeeper) ImportKeyJSON(
+	keyJSON []byte,
+	filePassphrase string,
+	passphrase string,
+) (gethcommon.Address, error) {
+	if _, err := keyFileAddress(keyJSON); err != nil {
+		return gethcommon.Address{}, fmt.Errorf("%w: no address", InvalidKeyFile)
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, filePassphrase)
+	if err != nil {
+		return gethcommon.Address{}, fmt.Errorf("%w: %v", InvalidKeyFile, err)
+	}
+
+	if passphrase != filePassphrase {
+		return wk.importKey(key.PrivateKey, passphrase, "")
+	}
+
+	if err := wk.ks.importKeyJSON(key.Address, keyJSON); err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	return key.Address, wk.metadata.add(key.Address, "")
+}
+
+// Imports a 0x-prefixed hex private key string
+func (wk *WalletKeeper) ImportHexKey(hexKey string, passphrase string) (gethcommon.Address, error) {
+	hexKey = strings.TrimSpace(hexKey)
+	if !strings.HasPrefix(hexKey, "0x") && !strings.HasPrefix(hexKey, "0X") {
+		return gethcommon.Address{}, fmt.Errorf("%w: missing 0x prefix", InvalidPrivateKey)
+	}
+
+	privKey, err := crypto.HexToECDSA(hexKey[2:])
+	if err != nil {
+		return gethcommon.Address{}, fmt.Errorf("%w: expected 32 bytes of hex", InvalidPrivateKey)
+	}
+
+	return wk.importKey(privKey, passphrase, "")
+}
+
+// Copies all key files of a geth or Clef keystore directory. Key files are
+// copied as they are, still encrypted with their own passphrases.
+func (wk *WalletKeeper) ImportKeystoreDirectory(dir string) (*ImportReport, error) {
+	source := NewDirectoryStorage(dir)
+
+	names, err := source.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	report := new(ImportReport)
+
+	for _, name := range names {
+		keyJSON, err := source.ReadFile(name)
+		if err != nil {
+			return nil, FileSystemAccess
+		}
+
+		address, err := keyFileAddress(keyJSON)
+		if err != nil || !isKeyFile(keyJSON) {
+			report.Skipped = append(report.Skipped, name)
+			continue
+		}
+
+		if wk.ks.HasAddress(address) {
+			report.Duplicates = append(report.Duplicates, address)
+			continue
+		}
+
+		if err := wk.ks.importKeyJSON(address, keyJSON); err != nil {
+			return nil, err
+		}
+		if err := wk.metadata.add(address, ""); err != nil {
+			return nil, err
+		}
+
+		report.Imported = append(report.Imported, address)
+	}
+
+	if len(report.Imported) == 0 && len(report.Duplicates) == 0 {
+		return report, fmt.Errorf("%w: no key files in %s", InvalidKeyFile, dir)
+	}
+
+	return report, nil
+}
+
+// Imports a wallet from the 2014 Ethereum presale, encrypted with
+// presalePassphrase. The key is re-encrypted with passphrase.
+func (wk *WalletKeeper) ImportPresaleWallet(
+	presaleJSON []byte,
+	presalePassphrase string,
+	passphrase string,
+) (gethcommon.Address, error) {
+	var presale struct {
+		EncSeed string `json:"encseed"`
+		EthAddr string `json:"ethaddr"`
+	}
+
+	if err := json.Unmarshal(presaleJSON, &presale); err != nil || presale.EncSeed == "" {
+		return gethcommon.Address{}, fmt.Errorf("%w: malformed JSON", InvalidPresaleWallet)
+	}
+
+	encSeed, err := hex.DecodeString(presale.EncSeed)
+	if err != nil || len(encSeed) < 2*aes.BlockSize || len(encSeed)%aes.BlockSize != 0 {
+		return gethcommon.Address{}, fmt.Errorf("%w: malformed seed", InvalidPresaleWallet)
+	}
+
+	// Same scheme as geth's ImportPreSaleKey
+	iv, cipherText := encSeed[:aes.BlockSize], encSeed[aes.BlockSize:]
+	derivedKey := pbkdf2.Key([]byte(presalePassphrase), []byte(presalePassphrase), 2000, 16, sha256.New)
+
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	plainText := make([]byte, len(cipherText))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plainText, cipherText)
+
+	seed, ok := pkcs7Unpad(plainText)
+	if !ok {
+		return gethcommon.Address{}, UnauthorizedAccess
+	}
+
+	privKey, err := crypto.ToECDSA(crypto.Keccak256(seed))
+	if err != nil {
+		return gethcommon.Address{}, InvalidPresaleWallet
+	}
+
+	address := crypto.PubkeyToAddress(privKey.PublicKey)
+	if !strings.EqualFold(hex.EncodeToString(address[:]), strings.TrimPrefix(presale.EthAddr, "0x")) {
+		return gethcommon.Address{}, UnauthorizedAccess
+	}
+
+	return wk.importKey(privKey, passphrase, "")
+}
+
+// Has the fields of an encrypted V3 (or V1) key file
+func isKeyFile(keyJSON []byte) bool {
+	var key struct {
+		Crypto    *keystore.CryptoJSON `json:"crypto"`
+		CryptoOld *keystore.CryptoJSON `json:"Crypto"`
+	}
+
+	if err := json.Unmarshal(keyJSON, &key); err != nil {
+		return false
+	}
+
+	return (key.Crypto != nil && key.Crypto.CipherText != "") || (key.CryptoOld != nil && key.CryptoOld.CipherText != "")
+}
+
+func pkcs7Unpad(data []byte) ([]byte, bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize || padding > len(data) {
+		return nil, false
+	}
+
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, false
+		}
+	}
+
+	return data[:len(data)-padding], true
+}
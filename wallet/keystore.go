@@ -0,0 +1,352 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+// V3 key files kept in a Storage, same format and naming as geth's keystore.
+// Also the default Signer of the wallet. Accounts have to be unlocked
+// to sign, decrypted keys are only held in memory.
+type keyStore struct {
+	storage Storage
+	scryptN int
+	scryptP int
+
+	mu       sync.Mutex
+	unlocked map[gethcommon.Address]*ecdsa.PrivateKey
+
+	// Addresses of the key files by name, so that only new files are read
+	indexMu sync.Mutex
+	index   map[string]gethcommon.Address
+}
+
+type keyFile struct {
+	name    string
+	address gethcommon.Address
+}
+
+func newKeyStore(storage Storage, scryptN int, scryptP int) *keyStore {
+	return &keyStore{
+		storage:  storage,
+		scryptN:  scryptN,
+		scryptP:  scryptP,
+		unlocked: make(map[gethcommon.Address]*ecdsa.PrivateKey),
+		index:    make(map[string]gethcommon.Address),
+	}
+}
+
+// Accounts ordered by key file name, i.e. by creation time
+func (ks *keyStore) Accounts() ([]gethcommon.Address, error) {
+	files, err := ks.files()
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]gethcommon.Address, len(files))
+	for i, file := range files {
+		addresses[i] = file.address
+	}
+
+	return addresses, nil
+}
+
+func (ks *keyStore) HasAddress(address gethcommon.Address) bool {
+	_, err := ks.find(address)
+	return err == nil
+}
+
+func (ks *keyStore) ImportECDSA(privKey *ecdsa.PrivateKey, passphrase string) (gethcommon.Address, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	key := &keystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(privKey.PublicKey),
+		PrivateKey: privKey,
+	}
+
+	keyJSON, err := keystore.EncryptKey(key, passphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	return key.Address, ks.importKeyJSON(key.Address, keyJSON)
+}
+
+// Adds a key file as is, still encrypted with its own passphrase
+func (ks *keyStore) importKeyJSON(address gethcommon.Address, keyJSON []byte) error {
+	if ks.HasAddress(address) {
+		return AccountAlreadyExists
+	}
+
+	return ks.storage.WriteFile(path.Join(keystoreDir, keyFileName(address)), keyJSON)
+}
+
+func (ks *keyStore) keyJSON(address gethcommon.Address) ([]byte, error) {
+	name, err := ks.find(address)
+	if err != nil {
+		return nil, err
+	}
+
+	keyJSON, err := ks.storage.ReadFile(name)
+	if err != nil {
+		return nil, FileSystemAccess
+	}
+
+	return keyJSON, nil
+}
+
+func (ks *keyStore) decrypt(address gethcommon.Address, passphrase string) (*keystore.Key, error) {
+	keyJSON, err := ks.keyJSON(address)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, UnauthorizedAccess
+	}
+
+	return key, nil
+}
+
+// Re-encrypts the key file in place
+func (ks *keyStore) Update(address gethcommon.Address, passphrase string, newPassphrase string) error {
+	name, err := ks.find(address)
+	if err != nil {
+		return err
+	}
+
+	key, err := ks.decrypt(address, passphrase)
+	if err != nil {
+		return err
+	}
+
+	keyJSON, err := keystore.EncryptKey(key, newPassphrase, ks.scryptN, ks.scryptP)
+	if err != nil {
+		return err
+	}
+
+	return ks.storage.WriteFile(name, keyJSON)
+}
+
+func (ks *keyStore) Delete(address gethcommon.Address, passphrase string) error {
+	name, err := ks.find(address)
+	if err != nil {
+		return err
+	}
+
+	if _, err := ks.decrypt(address, passphrase); err != nil {
+		return err
+	}
+
+	if err := ks.storage.Remove(name); err != nil {
+		return FileSystemAccess
+	}
+	ks.Lock(address)
+
+	return nil
+}
+
+// Keeps the decrypted key in memory until Lock
+func (ks *keyStore) Unlock(address gethcommon.Address, passphrase string) error {
+	key, err := ks.decrypt(address, passphrase)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if existing, ok := ks.unlocked[address]; ok {
+		zeroKey(existing)
+	}
+	ks.unlocked[address] = key.PrivateKey
+
+	return nil
+}
+
+func (ks *keyStore) Lock(address gethcommon.Address) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if key, ok := ks.unlocked[address]; ok {
+		zeroKey(key)
+		delete(ks.unlocked, address)
+	}
+}
+
+func (ks *keyStore) LockAll() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	for address, key := range ks.unlocked {
+		zeroKey(key)
+		delete(ks.unlocked, address)
+	}
+}
+
+func (ks *keyStore) SignTx(
+	account gethcommon.Address,
+	tx *types.Transaction,
+	chainId *big.Int,
+) (*types.Transaction, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, ok := ks.unlocked[account]
+	if !ok {
+		return nil, AccountLocked
+	}
+
+	return types.SignTx(tx, types.LatestSignerForChainID(chainId), key)
+}
+
+func (ks *keyStore) SignData(account gethcommon.Address, mimeType string, data []byte) ([]byte, error) {
+	return signData(ks.SignHash, account, mimeType, data)
+}
+
+// Returns a signature with V in {27, 28}
+func (ks *keyStore) SignHash(account gethcommon.Address, hash []byte) ([]byte, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, ok := ks.unlocked[account]
+	if !ok {
+		return nil, AccountLocked
+	}
+
+	signature, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, err
+	}
+
+	signature[crypto.RecoveryIDOffset] += 27
+
+	return signature, nil
+}
+
+// The key must not be kept beyond use
+func (ks *keyStore) withKey(
+	account gethcommon.Address,
+	use func(privKey *ecdsa.PrivateKey) ([]byte, error),
+) ([]byte, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, ok := ks.unlocked[account]
+	if !ok {
+		return nil, AccountLocked
+	}
+
+	return use(key)
+}
+
+func (ks *keyStore) find(address gethcommon.Address) (string, error) {
+	files, err := ks.files()
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range files {
+		if file.address == address {
+			return file.name, nil
+		}
+	}
+
+	return "", AccountNotFound
+}
+
+// Files that aren't key files are skipped, same as in geth's keystore.
+// Like geth, only lists the directory and reads the files that are new
+// since the last call, e.g. added by geth or Clef.
+func (ks *keyStore) files() ([]keyFile, error) {
+	names, err := ks.storage.List(keystoreDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ks.indexMu.Lock()
+	defer ks.indexMu.Unlock()
+
+	listed := make(map[string]bool, len(names))
+	files := make([]keyFile, 0, len(names))
+	seen := make(map[gethcommon.Address]bool, len(names))
+
+	for _, name := range names {
+		listed[name] = true
+
+		address, ok := ks.index[name]
+		if !ok {
+			keyJSON, err := ks.storage.ReadFile(name)
+			if err != nil {
+				continue
+			}
+
+			if address, err = keyFileAddress(keyJSON); err != nil {
+				continue
+			}
+			ks.index[name] = address
+		}
+
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+
+		files = append(files, keyFile{name, address})
+	}
+
+	for name := range ks.index {
+		if !listed[name] {
+			delete(ks.index, name)
+		}
+	}
+
+	return files, nil
+}
+
+func keyFileAddress(keyJSON []byte) (gethcommon.Address, error) {
+	var key struct {
+		Address string `json:"address"`
+	}
+
+	if err := json.Unmarshal(keyJSON, &key); err != nil || !gethcommon.IsHexAddress(key.Address) {
+		return gethcommon.Address{}, InvalidPrivateKey
+	}
+
+	return gethcommon.HexToAddress(key.Address), nil
+}
+
+// Same naming as geth's keystore: UTC--<created at>--<address>
+func keyFileName(address gethcommon.Address) string {
+	ts := time.Now().UTC()
+
+	return fmt.Sprintf(
+		"UTC--%04d-%02d-%02dT%02d-%02d-%02d.%09dZ--%s",
+		ts.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), ts.Second(), ts.Nanosecond(),
+		hex.EncodeToString(address[:]),
+	)
+}
+
+func zeroKey(key *ecdsa.PrivateKey) {
+	bits := key.D.Bits()
+	for i := range bits {
+		bits[i] = 0
+	}
+}
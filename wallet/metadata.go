@@ -0,0 +1,176 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+type AccountInfo struct {
+	Address        gethcommon.Address `json:"address"`
+	Label          string             `json:"label,omitempty"`
+	Tags           []string           `json:"tags,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt"`
+	DerivationPath string             `json:"derivationPath,omitempty"`
+
+	// Set for addresses without a key. Those derived from an extended
+	// public key have it in ExtendedKey, and DerivationPath relative to it.
+	WatchOnly   bool   `json:"watchOnly,omitempty"`
+	ExtendedKey string `json:"extendedKey,omitempty"`
+}
+
+// Sidecar store for account metadata, kept next to the keystore
+// since V3 key files have no room for it.
+type metadataStore struct {
+	storage Storage
+
+	mu       sync.Mutex
+	accounts map[gethcommon.Address]*AccountInfo
+}
+
+func loadMetadataStore(storage Storage) (*metadataStore, error) {
+	store := &metadataStore{
+		storage:  storage,
+		accounts: make(map[gethcommon.Address]*AccountInfo),
+	}
+
+	data, err := storage.ReadFile(metadataFileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, FileSystemAccess
+	}
+
+	var infos []*AccountInfo
+	if err := json.Unmarshal(data, &infos); err != nil {
+		return nil, err
+	}
+
+	for _, info := range infos {
+		store.accounts[info.Address] = info
+	}
+
+	return store, nil
+}
+
+func (ms *metadataStore) get(address gethcommon.Address) (AccountInfo, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	info, ok := ms.accounts[address]
+	if !ok {
+		return AccountInfo{Address: address}, false
+	}
+
+	return info.copy(), true
+}
+
+// Creates a record for the address unless there's one already.
+// A watch-only record becomes a regular one, as the key is there now.
+func (ms *metadataStore) add(address gethcommon.Address, derivationPath string) error {
+	return ms.update(address, func(info *AccountInfo) {
+		if info.CreatedAt.IsZero() {
+			info.CreatedAt = time.Now().UTC()
+		}
+		if info.WatchOnly {
+			info.WatchOnly = false
+			info.ExtendedKey = ""
+			info.DerivationPath = ""
+		}
+		if info.DerivationPath == "" {
+			info.DerivationPath = derivationPath
+		}
+	})
+}
+
+func (ms *metadataStore) watchOnly() []*AccountInfo {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var infos []*AccountInfo
+	for _, info := range ms.accounts {
+		if info.WatchOnly {
+			copied := info.copy()
+			infos = append(infos, &copied)
+		}
+	}
+
+	return infos
+}
+
+func (ms *metadataStore) update(address gethcommon.Address, change func(info *AccountInfo)) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	info, ok := ms.accounts[address]
+	if !ok {
+		info = &AccountInfo{Address: address}
+		ms.accounts[address] = info
+	}
+
+	change(info)
+
+	return ms.save()
+}
+
+func (ms *metadataStore) remove(address gethcommon.Address) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.accounts[address]; !ok {
+		return nil
+	}
+
+	delete(ms.accounts, address)
+
+	return ms.save()
+}
+
+// Must be called with the lock held
+func (ms *metadataStore) save() error {
+	infos := make([]*AccountInfo, 0, len(ms.accounts))
+	for _, info := range ms.accounts {
+		infos = append(infos, info)
+	}
+	sortAccountInfos(infos)
+
+	data, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ms.storage.WriteFile(metadataFileName, data)
+}
+
+func (info AccountInfo) copy() AccountInfo {
+	info.Tags = append([]string(nil), info.Tags...)
+	return info
+}
+
+func (info AccountInfo) HasTag(tag string) bool {
+	for _, t := range info.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Oldest first, then by address for records without a creation time
+func sortAccountInfos(infos []*AccountInfo) {
+	sort.SliceStable(infos, func(i, j int) bool {
+		if !infos[i].CreatedAt.Equal(infos[j].CreatedAt) {
+			return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+		}
+
+		return bytes.Compare(infos[i].Address[:], infos[j].Address[:]) < 0
+	})
+}
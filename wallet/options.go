@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+const DefaultProfile = ""
+
+type Options struct {
+	// Root directory of the wallet for the default DirectoryStorage,
+	// keys are kept in Path/keystore. Resolved from Profile when empty.
+	Path string
+
+	// Named profile, e.g. "prod" or "staging". Each profile gets an isolated
+	// directory under ~/evm/wallet/profiles. Ignored when Path is set.
+	Profile string
+
+	// Scrypt cost parameters for keys and the HD seed.
+	// Zero values fall back to the keystore standard ones.
+	ScryptN int
+	ScryptP int
+
+	// Use cheap scrypt parameters. Only meant for tests.
+	LightScrypt bool
+
+	// Allows accounts to stay unlocked after Unlock
+	// so they can be used with autosign.
+	InsecureUnlockAllowed bool
+
+	// Signs with keys held elsewhere, e.g. an ExternalSigner,
+	// instead of the local keystore
+	Signer Signer
+
+	// Where keys and other wallet files are kept, e.g. a MemoryStorage
+	// or a VaultStorage. Defaults to a DirectoryStorage at Path.
+	Storage Storage
+
+	// Append-only log of signing requests on disk. Defaults to audit.log
+	// in the wallet's Storage, or to <vault>.audit.log next to a VaultStorage.
+	// The latter is not encrypted.
+	AuditLogPath string
+}
+
+func DefaultOptions() Options {
+	return Options{
+		ScryptN: keystore.StandardScryptN,
+		ScryptP: keystore.StandardScryptP,
+	}
+}
+
+func ProfileOptions(profile string) Options {
+	opts := DefaultOptions()
+	opts.Profile = profile
+
+	return opts
+}
+
+func (opts Options) resolve() (Options, error) {
+	if opts.Path == "" {
+		if strings.ContainsAny(opts.Profile, `/\`) || opts.Profile == "." || opts.Profile == ".." {
+			return opts, InvalidProfile
+		}
+
+		userHomeDir, err := os.UserHomeDir()
+		if err != nil {
+			return opts, FileSystemAccess
+		}
+
+		opts.Path = filepath.Join(userHomeDir, "evm", "wallet")
+		if opts.Profile != DefaultProfile {
+			opts.Path = filepath.Join(opts.Path, "profiles", opts.Profile)
+		}
+	}
+
+	if opts.Storage == nil {
+		opts.Storage = NewDirectoryStorage(opts.Path)
+	}
+
+	switch {
+	case opts.LightScrypt:
+		opts.ScryptN = keystore.LightScryptN
+		opts.ScryptP = keystore.LightScryptP
+	case opts.ScryptN == 0 || opts.ScryptP == 0:
+		opts.ScryptN = keystore.StandardScryptN
+		opts.ScryptP = keystore.StandardScryptP
+	}
+
+	return opts, nil
+}
+
+// A vault would be rewritten as a whole on every signature,
+// so its audit log is kept in a file next to it
+func (opts Options) auditLogStorage() (Storage, string) {
+	if opts.AuditLogPath != "" {
+		return NewDirectoryStorage(filepath.Dir(opts.AuditLogPath)), filepath.Base(opts.AuditLogPath)
+	}
+
+	if vault, ok := opts.Storage.(*VaultStorage); ok {
+		return NewDirectoryStorage(filepath.Dir(vault.path)), filepath.Base(vault.path) + "." + auditLogFileName
+	}
+
+	return opts.Storage, auditLogFileName
+}
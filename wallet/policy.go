@@ -0,0 +1,373 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0xNSHuman/dapp-tools/utils"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const spendingWindow = 24 * time.Hour
+
+// Declarative rules checked before every signature.
+// Accounts without rules of their own fall back to Default,
+// and are not allowed to sign at all if there's no Default.
+// Typed data is only signed for listed contracts, personal messages only
+// if allowed, and raw hashes never, since any of them could authorize
+// a transfer without a transaction.
+//
+// Amounts are strings in wei, either decimal or 0x-prefixed hex:
+//
+//	{
+//	  "accounts": {
+//	    "0x...": {
+//	      "allowedDestinations": ["0x..."],
+//	      "allowedMethods": {"0x...": ["0xa9059cbb"]},
+//	      "maxValuePerTx": "1000000000000000000",
+//	      "maxValuePerDay": "5000000000000000000",
+//	      "maxFeeCap": "100000000000",
+//	      "chainIds": [1, 10],
+//	      "allowedTypedDataContracts": ["0x..."],
+//	      "allowMessages": true
+//	    }
+//	  }
+//	}
+type SigningPolicy struct {
+	Default  *AccountPolicy                        `json:"default,omitempty"`
+	Accounts map[gethcommon.Address]*AccountPolicy `json:"accounts,omitempty"`
+
+	mu       sync.Mutex
+	spending map[gethcommon.Address][]spending
+}
+
+type AccountPolicy struct {
+	// Destinations the account may send to. Contracts listed in AllowedMethods
+	// are allowed implicitly. Any destination is allowed if both are empty.
+	AllowedDestinations []gethcommon.Address `json:"allowedDestinations,omitempty"`
+
+	// 4-byte method selectors allowed per contract
+	AllowedMethods map[gethcommon.Address][]hexutil.Bytes `json:"allowedMethods,omitempty"`
+
+	MaxValuePerTx *math.HexOrDecimal256 `json:"maxValuePerTx,omitempty"`
+
+	// Limit for the total value signed over a rolling 24h window
+	MaxValuePerDay *math.HexOrDecimal256 `json:"maxValuePerDay,omitempty"`
+
+	// Limit for the max fee per gas (gas price for legacy transactions)
+	MaxFeeCap *math.HexOrDecimal256 `json:"maxFeeCap,omitempty"`
+
+	// Chains the account may sign for. Any chain is allowed if empty.
+	ChainIDs []uint64 `json:"chainIds,omitempty"`
+
+	// Verifying contracts of the EIP-712 typed data the account may sign,
+	// e.g. a permit of a token. Typed data without one is not allowed.
+	AllowedTypedDataContracts []gethcommon.Address `json:"allowedTypedDataContracts,omitempty"`
+
+	// Whether the account may sign personal messages, e.g. to log in
+	AllowMessages bool `json:"allowMessages,omitempty"`
+}
+
+type spending struct {
+	time  time.Time
+	value *big.Int
+}
+
+func LoadSigningPolicy(path string) (*SigningPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, FileSystemAccess
+	}
+
+	return ParseSigningPolicy(data)
+}
+
+func ParseSigningPolicy(data []byte) (*SigningPolicy, error) {
+	policy := new(SigningPolicy)
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidPolicy, err)
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// Policy checked before every signature, or nil to sign unrestricted
+func (wk *WalletKeeper) SetSigningPolicy(policy *SigningPolicy) {
+	wk.policyMu.Lock()
+	defer wk.policyMu.Unlock()
+
+	wk.policy = policy
+}
+
+func (wk *WalletKeeper) SigningPolicy() *SigningPolicy {
+	wk.policyMu.Lock()
+	defer wk.policyMu.Unlock()
+
+	return wk.policy
+}
+
+func (p *SigningPolicy) validate() error {
+	rules := make([]*AccountPolicy, 0, len(p.Accounts)+1)
+	if p.Default != nil {
+		rules = append(rules, p.Default)
+	}
+	for _, rule := range p.Accounts {
+		rules = append(rules, rule)
+	}
+
+	for _, rule := range rules {
+		if rule == nil {
+			continue
+		}
+
+		for contract, selectors := range rule.AllowedMethods {
+			for _, selector := range selectors {
+				if len(selector) != 4 {
+					return fmt.Errorf("%w: bad method selector %s for %s", InvalidPolicy, selector, contract.Hex())
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Checks the transaction against the signer's rules and reserves its value
+// in the daily spending window. The returned func releases the reservation
+// and must be called if the transaction doesn't get signed after all.
+func (p *SigningPolicy) authorize(
+	chainId *big.Int,
+	tx *types.Transaction,
+	signer gethcommon.Address,
+) (func(), error) {
+	rule, err := p.rule(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rule.checkChain(chainId, tx); err != nil {
+		return nil, err
+	}
+	if err := rule.checkDestination(tx); err != nil {
+		return nil, err
+	}
+	if err := rule.checkFees(tx); err != nil {
+		return nil, err
+	}
+
+	value := tx.Value()
+	if rule.MaxValuePerTx != nil && value.Cmp((*big.Int)(rule.MaxValuePerTx)) > 0 {
+		return nil, violation("value %v exceeds the per-transaction limit", value)
+	}
+
+	if rule.MaxValuePerDay == nil || value.Sign() == 0 {
+		return func() {}, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.spending == nil {
+		p.spending = make(map[gethcommon.Address][]spending)
+	}
+
+	now := time.Now()
+	spent := p.spentSince(signer, now.Add(-spendingWindow))
+
+	if new(big.Int).Add(spent, value).Cmp((*big.Int)(rule.MaxValuePerDay)) > 0 {
+		return nil, violation("value %v exceeds the daily limit, %v already spent", value, spent)
+	}
+
+	record := spending{now, value}
+	p.spending[signer] = append(p.spending[signer], record)
+
+	return func() { p.release(signer, record) }, nil
+}
+
+// Checks data of the given kind against the signer's rules
+func (p *SigningPolicy) authorizeData(kind AuditKind, data []byte, signer gethcommon.Address) error {
+	rule, err := p.rule(signer)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case AuditKindTypedData:
+		return rule.checkTypedData(data)
+	case AuditKindMessage:
+		if !rule.AllowMessages {
+			return violation("personal messages are not allowed")
+		}
+
+		return nil
+	}
+
+	return violation("raw hashes can't be checked against the policy")
+}
+
+func (p *SigningPolicy) rule(signer gethcommon.Address) (*AccountPolicy, error) {
+	rule, ok := p.Accounts[signer]
+	if !ok || rule == nil {
+		rule = p.Default
+	}
+	if rule == nil {
+		return nil, violation("no rules for %s", signer.Hex())
+	}
+
+	return rule, nil
+}
+
+// Must be called with the lock held. Drops records that left the window.
+func (p *SigningPolicy) spentSince(signer gethcommon.Address, since time.Time) *big.Int {
+	records := p.spending[signer]
+	for len(records) > 0 && records[0].time.Before(since) {
+		records = records[1:]
+	}
+	p.spending[signer] = records
+
+	total := new(big.Int)
+	for _, record := range records {
+		total.Add(total, record.value)
+	}
+
+	return total
+}
+
+func (p *SigningPolicy) release(signer gethcommon.Address, record spending) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	records := p.spending[signer]
+	for i := range records {
+		if records[i] == record {
+			p.spending[signer] = append(records[:i:i], records[i+1:]...)
+			return
+		}
+	}
+}
+
+func (rule *AccountPolicy) checkChain(chainId *big.Int, tx *types.Transaction) error {
+	if len(rule.ChainIDs) == 0 {
+		return nil
+	}
+
+	if chainId == nil || !chainId.IsUint64() {
+		return violation("chain ID %v is not allowed", chainId)
+	}
+
+	// Typed transactions carry the chain ID they will be signed for
+	if tx.Type() != types.LegacyTxType && tx.ChainId().Cmp(chainId) != 0 {
+		return violation("transaction chain ID %v doesn't match %v", tx.ChainId(), chainId)
+	}
+
+	for _, allowed := range rule.ChainIDs {
+		if allowed == chainId.Uint64() {
+			return nil
+		}
+	}
+
+	return violation("chain ID %v is not allowed", chainId)
+}
+
+func (rule *AccountPolicy) checkDestination(tx *types.Transaction) error {
+	if len(rule.AllowedDestinations) == 0 && len(rule.AllowedMethods) == 0 {
+		return nil
+	}
+
+	to := tx.To()
+	if to == nil {
+		return violation("contract creation is not allowed")
+	}
+
+	if selectors, ok := rule.AllowedMethods[*to]; ok {
+		data := tx.Data()
+		if len(data) < 4 {
+			return violation("call to %s without an allowed method", to.Hex())
+		}
+
+		for _, selector := range selectors {
+			if bytes.Equal(data[:4], selector) {
+				return nil
+			}
+		}
+
+		return violation("method %s is not allowed on %s", hexutil.Encode(data[:4]), to.Hex())
+	}
+
+	for _, allowed := range rule.AllowedDestinations {
+		if allowed == *to {
+			return nil
+		}
+	}
+
+	return violation("destination %s is not allowed", to.Hex())
+}
+
+func (rule *AccountPolicy) checkTypedData(typedDataJSON []byte) error {
+	typedData, err := utils.ParseTypedData(typedDataJSON)
+	if err != nil {
+		return InvalidTypedData
+	}
+
+	domain := typedData.Domain
+	if !gethcommon.IsHexAddress(domain.VerifyingContract) {
+		return violation("typed data without a verifying contract is not allowed")
+	}
+
+	contract := gethcommon.HexToAddress(domain.VerifyingContract)
+	allowed := false
+	for _, allowedContract := range rule.AllowedTypedDataContracts {
+		if allowedContract == contract {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return violation("typed data for %s is not allowed", contract.Hex())
+	}
+
+	if len(rule.ChainIDs) == 0 {
+		return nil
+	}
+
+	chainId := (*big.Int)(domain.ChainId)
+	if chainId == nil || !chainId.IsUint64() {
+		return violation("typed data chain ID %v is not allowed", chainId)
+	}
+
+	for _, allowedChain := range rule.ChainIDs {
+		if allowedChain == chainId.Uint64() {
+			return nil
+		}
+	}
+
+	return violation("typed data chain ID %v is not allowed", chainId)
+}
+
+func (rule *AccountPolicy) checkFees(tx *types.Transaction) error {
+	if rule.MaxFeeCap == nil {
+		return nil
+	}
+
+	if tx.GasFeeCap().Cmp((*big.Int)(rule.MaxFeeCap)) > 0 {
+		return violation("fee cap %v exceeds the limit of %v", tx.GasFeeCap(), (*big.Int)(rule.MaxFeeCap))
+	}
+
+	return nil
+}
+
+func violation(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", PolicyViolation, fmt.Sprintf(format, args...))
+}
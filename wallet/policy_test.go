@@ -0,0 +1,244 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	testRecipient = gethcommon.HexToAddress("0x00000000000000000000000000000000000000aa")
+	testToken     = gethcommon.HexToAddress("0x00000000000000000000000000000000000000bb")
+)
+
+func newTestPolicyWallet(t *testing.T, policyJSON string) (*WalletKeeper, gethcommon.Address) {
+	t.Helper()
+
+	wk, address := newTestWallet(t)
+
+	policy, err := ParseSigningPolicy([]byte(policyJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk.SetSigningPolicy(policy)
+
+	return wk, address
+}
+
+func testTransaction(to gethcommon.Address, value int64, data []byte) *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   big.NewInt(1),
+		To:        &to,
+		Value:     big.NewInt(value),
+		Gas:       100000,
+		GasFeeCap: big.NewInt(100),
+		GasTipCap: big.NewInt(1),
+		Data:      data,
+	})
+}
+
+// EIP-2612 permit of testToken
+func testPermit(verifyingContract gethcommon.Address, chainId int) []byte {
+	return []byte(fmt.Sprintf(`{
+		"types": {
+			"EIP712Domain": [
+				{"name": "name", "type": "string"},
+				{"name": "chainId", "type": "uint256"},
+				{"name": "verifyingContract", "type": "address"}
+			],
+			"Permit": [
+				{"name": "owner", "type": "address"},
+				{"name": "spender", "type": "address"},
+				{"name": "value", "type": "uint256"},
+				{"name": "nonce", "type": "uint256"},
+				{"name": "deadline", "type": "uint256"}
+			]
+		},
+		"primaryType": "Permit",
+		"domain": {"name": "Token", "chainId": %d, "verifyingContract": "%s"},
+		"message": {
+			"owner": "%[2]s",
+			"spender": "%[2]s",
+			"value": "1000000000000000000000",
+			"nonce": 0,
+			"deadline": 1
+		}
+	}`, chainId, verifyingContract.Hex()))
+}
+
+func TestSigningPolicyTransactions(t *testing.T) {
+	transfer := []byte{0xa9, 0x05, 0x9c, 0xbb, 0}
+
+	wk, address := newTestPolicyWallet(t, fmt.Sprintf(`{
+		"default": {
+			"allowedDestinations": ["%s"],
+			"allowedMethods": {"%s": ["0xa9059cbb"]},
+			"maxValuePerTx": "10",
+			"maxFeeCap": "100",
+			"chainIds": [1]
+		}
+	}`, testRecipient.Hex(), testToken.Hex()))
+
+	chainId := big.NewInt(1)
+	other := gethcommon.HexToAddress("0x00000000000000000000000000000000000000cc")
+
+	tests := []struct {
+		name    string
+		chainId *big.Int
+		tx      *types.Transaction
+		allowed bool
+	}{
+		{"allowed destination", chainId, testTransaction(testRecipient, 10, nil), true},
+		{"allowed method", chainId, testTransaction(testToken, 0, transfer), true},
+		{"other destination", chainId, testTransaction(other, 1, nil), false},
+		{"other method", chainId, testTransaction(testToken, 0, []byte{1, 2, 3, 4}), false},
+		{"no method", chainId, testTransaction(testToken, 0, nil), false},
+		{"value over the limit", chainId, testTransaction(testRecipient, 11, nil), false},
+		{"other chain", big.NewInt(5), testTransaction(testRecipient, 1, nil), false},
+		{"fee cap over the limit", chainId, types.NewTx(&types.LegacyTx{
+			To:       &testRecipient,
+			Gas:      21000,
+			GasPrice: big.NewInt(101),
+		}), false},
+		{"contract creation", chainId, types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainId,
+			Gas:       100000,
+			GasFeeCap: big.NewInt(1),
+			Data:      []byte{1},
+		}), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := wk.SignTransaction(test.chainId, test.tx, address, false)
+			if test.allowed && err != nil {
+				t.Fatal(err)
+			}
+			if !test.allowed && !errors.Is(err, PolicyViolation) {
+				t.Fatalf("err = %v, want PolicyViolation", err)
+			}
+		})
+	}
+}
+
+func TestSigningPolicyNoRules(t *testing.T) {
+	wk, address := newTestPolicyWallet(t, `{"accounts": {}}`)
+
+	_, err := wk.SignTransaction(big.NewInt(1), testTransaction(testRecipient, 1, nil), address, false)
+	if !errors.Is(err, PolicyViolation) {
+		t.Fatalf("err = %v, want PolicyViolation", err)
+	}
+}
+
+func TestSigningPolicyDailyLimit(t *testing.T) {
+	wk, address := newTestPolicyWallet(t, `{"default": {"maxValuePerDay": "10"}}`)
+	chainId := big.NewInt(1)
+
+	if _, err := wk.SignTransaction(chainId, testTransaction(testRecipient, 6, nil), address, false); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := wk.SignTransaction(chainId, testTransaction(testRecipient, 5, nil), address, false)
+	if !errors.Is(err, PolicyViolation) {
+		t.Fatalf("err = %v, want PolicyViolation", err)
+	}
+
+	// Failed signatures don't count, neither in a batch
+	txs := []*types.Transaction{
+		testTransaction(testRecipient, 1, nil),
+		testTransaction(testRecipient, 2, nil),
+	}
+	if _, err := wk.SignTransactions(chainId, txs, address, true); !errors.Is(err, AccountLocked) {
+		t.Fatalf("err = %v, want AccountLocked", err)
+	}
+
+	if _, err := wk.SignTransaction(chainId, testTransaction(testRecipient, 4, nil), address, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records that left the window don't count either
+	policy := wk.SigningPolicy()
+	policy.mu.Lock()
+	for i := range policy.spending[address] {
+		policy.spending[address][i].time = policy.spending[address][i].time.Add(-spendingWindow)
+	}
+	policy.mu.Unlock()
+
+	if _, err := wk.SignTransaction(chainId, testTransaction(testRecipient, 10, nil), address, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSigningPolicyData(t *testing.T) {
+	wk, address := newTestPolicyWallet(t, fmt.Sprintf(`{
+		"default": {"maxValuePerTx": "1", "chainIds": [1]},
+		"accounts": {
+			"%s": {
+				"maxValuePerTx": "1",
+				"chainIds": [1],
+				"allowedTypedDataContracts": ["%s"]
+			}
+		}
+	}`, "0x0000000000000000000000000000000000000001", testToken.Hex()))
+
+	tests := []struct {
+		name      string
+		typedData []byte
+	}{
+		{"permit of another token", testPermit(testRecipient, 1)},
+		{"permit on another chain", testPermit(testToken, 5)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := wk.SignTypedData(test.typedData, address, false); !errors.Is(err, PolicyViolation) {
+				t.Fatalf("err = %v, want PolicyViolation", err)
+			}
+		})
+	}
+
+	if _, err := wk.SignMessage([]byte("hello"), address, false); !errors.Is(err, PolicyViolation) {
+		t.Fatalf("message: err = %v, want PolicyViolation", err)
+	}
+	if _, err := wk.SignHash(make([]byte, gethcommon.HashLength), address, false); !errors.Is(err, PolicyViolation) {
+		t.Fatalf("hash: err = %v, want PolicyViolation", err)
+	}
+
+	// Allowed by the account's own rules
+	policy, err := ParseSigningPolicy([]byte(fmt.Sprintf(`{
+		"accounts": {
+			"%s": {
+				"chainIds": [1],
+				"allowedTypedDataContracts": ["%s"],
+				"allowMessages": true
+			}
+		}
+	}`, address.Hex(), testToken.Hex())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk.SetSigningPolicy(policy)
+
+	if _, err := wk.SignTypedData(testPermit(testToken, 1), address, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wk.SignTypedData(testPermit(testRecipient, 1), address, false); !errors.Is(err, PolicyViolation) {
+		t.Fatalf("err = %v, want PolicyViolation", err)
+	}
+	if _, err := wk.SignMessage([]byte("hello"), address, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rejections are audited like signatures
+	records, err := wk.VerifyAuditLog(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 7 {
+		t.Fatalf("got %d audit records, want 7", len(records))
+	}
+}
@@ -0,0 +1,195 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const seedFileVersion = 1
+
+// Stored HD seed record. The mnemonic is only ever stored encrypted,
+// using the same scrypt + AES-128-CTR scheme as V3 keystore files.
+type seedFile struct {
+	Version   int                 `json:"version"`
+	Crypto    keystore.CryptoJSON `json:"crypto"`
+	NextIndex uint32              `json:"nextIndex"`
+}
+
+type seedSecret struct {
+	Mnemonic       string `json:"mnemonic"`
+	SeedPassphrase string `json:"seedPassphrase"`
+}
+
+// Returns FileSystemAccess if the seed file exists but can't be read,
+// so that it's never taken for missing and overwritten
+func (wk *WalletKeeper) HasSeed() (bool, error) {
+	_, err := wk.opts.Storage.ReadFile(seedFileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, FileSystemAccess
+	}
+
+	return true, nil
+}
+
+// Derives the account at m/44'/60'/0'/0/index from the stored HD seed
+// and adds it to the keystore, encrypted with the same passphrase.
+func (wk *WalletKeeper) DeriveAccount(index uint32, passphrase string) (gethcommon.Address, error) {
+	file, err := wk.readSeedFile()
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	secret, err := decryptSeed(file, passphrase)
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	address, err := wk.importDerivedKey(secret.Mnemonic, secret.SeedPassphrase, index, passphrase)
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	if index >= file.NextIndex {
+		file.NextIndex = index + 1
+
+		if err := wk.writeSeedFile(file); err != nil {
+			return gethcommon.Address{}, err
+		}
+	}
+
+	return address, nil
+}
+
+// Re-encrypts the stored HD seed. Passphrases of the derived accounts
+// are changed separately with ChangePassphrase.
+func (wk *WalletKeeper) ChangeSeedPassphrase(passphrase string, newPassphrase string) error {
+	file, err := wk.readSeedFile()
+	if err != nil {
+		return err
+	}
+
+	secret, err := decryptSeed(file, passphrase)
+	if err != nil {
+		return err
+	}
+
+	cryptoJSON, err := encryptSeed(secret, newPassphrase, wk.opts.ScryptN, wk.opts.ScryptP)
+	if err != nil {
+		return err
+	}
+
+	file.Crypto = cryptoJSON
+
+	return wk.writeSeedFile(file)
+}
+
+func (wk *WalletKeeper) exportSeedPhrase(passphrase string) ([]byte, error) {
+	file, err := wk.readSeedFile()
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := decryptSeed(file, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(secret.Mnemonic), nil
+}
+
+func (wk *WalletKeeper) storeSeed(mnemonic string, seedPassphrase string, passphrase string) error {
+	secret := &seedSecret{NormalizeMnemonic(mnemonic), seedPassphrase}
+
+	cryptoJSON, err := encryptSeed(secret, passphrase, wk.opts.ScryptN, wk.opts.ScryptP)
+	if err != nil {
+		return err
+	}
+
+	return wk.writeSeedFile(&seedFile{
+		Version: seedFileVersion,
+		Crypto:  cryptoJSON,
+	})
+}
+
+func (wk *WalletKeeper) importDerivedKey(
+	mnemonic string,
+	seedPassphrase string,
+	index uint32,
+	passphrase string,
+) (gethcommon.Address, error) {
+	seed, err := seedFromMnemonic(mnemonic, seedPassphrase)
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	path := DerivationPath(index)
+
+	privKey, err := derivePrivateKey(seed, path)
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	address := crypto.PubkeyToAddress(privKey.PublicKey)
+	if wk.ks.HasAddress(address) {
+		return address, nil
+	}
+
+	return wk.importKey(privKey, passphrase, path.String())
+}
+
+func (wk *WalletKeeper) readSeedFile() (*seedFile, error) {
+	data, err := wk.opts.Storage.ReadFile(seedFileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, SeedNotFound
+	}
+	if err != nil {
+		return nil, FileSystemAccess
+	}
+
+	file := new(seedFile)
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (wk *WalletKeeper) writeSeedFile(file *seedFile) error {
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	return wk.opts.Storage.WriteFile(seedFileName, data)
+}
+
+func encryptSeed(secret *seedSecret, passphrase string, scryptN int, scryptP int) (keystore.CryptoJSON, error) {
+	secretJSON, err := json.Marshal(secret)
+	if err != nil {
+		return keystore.CryptoJSON{}, err
+	}
+
+	return keystore.EncryptDataV3(secretJSON, []byte(passphrase), scryptN, scryptP)
+}
+
+func decryptSeed(file *seedFile, passphrase string) (*seedSecret, error) {
+	secretJSON, err := keystore.DecryptDataV3(file.Crypto, passphrase)
+	if err != nil {
+		return nil, UnauthorizedAccess
+	}
+
+	secret := new(seedSecret)
+	if err := json.Unmarshal(secretJSON, secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,45 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+)
+
+// Memory storage whose seed file exists but can't be read
+type unreadableSeedStorage struct {
+	*MemoryStorage
+}
+
+func (s unreadableSeedStorage) ReadFile(name string) ([]byte, error) {
+	if name == seedFileName {
+		return nil, errors.New("permission denied")
+	}
+
+	return s.MemoryStorage.ReadFile(name)
+}
+
+func TestHasSeedUnreadable(t *testing.T) {
+	storage := unreadableSeedStorage{NewMemoryStorage()}
+	if err := storage.WriteFile(seedFileName, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+
+	wk, err := NewWalletKeeperWithOptions(nil, Options{Path: t.TempDir(), LightScrypt: true, Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wk.HasSeed(); err != FileSystemAccess {
+		t.Fatalf("err = %v, want FileSystemAccess", err)
+	}
+
+	// The existing seed must not be replaced by a new one
+	if err := wk.CreateWallet("pw"); err != FileSystemAccess {
+		t.Fatalf("err = %v, want FileSystemAccess", err)
+	}
+
+	data, _ := storage.MemoryStorage.ReadFile(seedFileName)
+	if string(data) != "{}" {
+		t.Fatal("seed file overwritten")
+	}
+}
@@ -0,0 +1,354 @@
+package wallet
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/0xNSHuman/dapp-tools/common"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+type SessionOptions struct {
+	// Time after which the account is locked. Zero means no limit.
+	Duration time.Duration
+
+	// Time without signatures after which the account is locked.
+	// Zero means no limit.
+	IdleTimeout time.Duration
+
+	// Number of autosigned signatures after which the account is locked.
+	// Zero means no limit.
+	MaxSignatures int
+}
+
+type SessionInfo struct {
+	Address    gethcommon.Address
+	UnlockedAt time.Time
+	LastUsedAt time.Time
+
+	// Zero if the session has no time limit
+	ExpiresAt   time.Time
+	IdleTimeout time.Duration
+
+	// When the account gets locked by time, the closer of ExpiresAt and
+	// LastUsedAt + IdleTimeout. Zero if neither is set.
+	Deadline time.Time
+
+	Signatures    int
+	MaxSignatures int
+}
+
+// Keys that sessions unlock and lock, the wallet's keystore
+// or a KeystoreSigner
+type keyUnlocker interface {
+	Unlock(address gethcommon.Address, passphrase string) error
+	Lock(address gethcommon.Address)
+}
+
+type session struct {
+	info  SessionInfo
+	timer *time.Timer
+}
+
+// Tracks unlocked accounts. Keys are unlocked in the keystore without
+// a timeout, and locked back here once any of the session limits is hit.
+type sessionManager struct {
+	ks keyUnlocker
+
+	mu       sync.Mutex
+	sessions map[gethcommon.Address]*session
+
+	// Signatures in flight per account, both autosigned ones and ones
+	// unlocked with unlockOnce. The key isn't locked while there are any.
+	pending map[gethcommon.Address]int
+}
+
+func newSessionManager(ks keyUnlocker) *sessionManager {
+	return &sessionManager{
+		ks:       ks,
+		sessions: make(map[gethcommon.Address]*session),
+		pending:  make(map[gethcommon.Address]int),
+	}
+}
+
+// Unlocks the account for a session limited by opts
+func (wk *WalletKeeper) UnlockAccountFor(
+	address gethcommon.Address,
+	passphrase string,
+	opts SessionOptions,
+) error {
+	if !wk.opts.InsecureUnlockAllowed {
+		return InsecureUnlockNotAllowed
+	}
+
+	// External signers keep their keys to themselves
+	if wk.signerApproves() {
+		return common.NotSupported
+	}
+
+	if err := wk.checkSigner(address); err != nil {
+		return err
+	}
+
+	return wk.sessions.open(address, passphrase, opts)
+}
+
+func (wk *WalletKeeper) LockAccount(address gethcommon.Address) {
+	wk.sessions.close(address)
+}
+
+func (wk *WalletKeeper) LockAll() {
+	wk.sessions.closeAll()
+}
+
+func (wk *WalletKeeper) Session(address gethcommon.Address) (SessionInfo, bool) {
+	return wk.sessions.get(address)
+}
+
+// Returns the currently unlocked accounts
+func (wk *WalletKeeper) Sessions() []SessionInfo {
+	return wk.sessions.list()
+}
+
+func (sm *sessionManager) open(address gethcommon.Address, passphrase string, opts SessionOptions) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	// An account unlocked without a timeout stays as is,
+	// the passphrase is only checked
+	if err := sm.ks.Unlock(address, passphrase); err != nil {
+		return err
+	}
+
+	// Replaces the previous session, if any, keeping the key unlocked
+	if _, ok := sm.sessions[address]; ok {
+		sm.end(address, false)
+	}
+
+	now := time.Now()
+	s := &session{
+		info: SessionInfo{
+			Address:       address,
+			UnlockedAt:    now,
+			LastUsedAt:    now,
+			IdleTimeout:   opts.IdleTimeout,
+			MaxSignatures: opts.MaxSignatures,
+		},
+	}
+	if opts.Duration > 0 {
+		s.info.ExpiresAt = now.Add(opts.Duration)
+	}
+
+	sm.sessions[address] = s
+	sm.schedule(s, now)
+
+	return nil
+}
+
+// Accounts a signature to the session, locking the account
+// if that was the last one allowed
+func (sm *sessionManager) use(address gethcommon.Address) error {
+	return sm.useN(address, 1)
+}
+
+// Accounts count signatures at once, or none if the session
+// doesn't have that many left
+func (sm *sessionManager) useN(address gethcommon.Address, count int) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.sessions[address]
+	if !ok {
+		return AccountLocked
+	}
+
+	// Out of signatures, the account is locked by release
+	if s.info.MaxSignatures > 0 && s.info.Signatures+count > s.info.MaxSignatures {
+		return AccountLocked
+	}
+
+	now := time.Now()
+	if s.expired(now) {
+		sm.end(address, true)
+		return AccountLocked
+	}
+
+	s.info.Signatures += count
+	s.info.LastUsedAt = now
+	sm.pending[address]++
+
+	if s.info.MaxSignatures > 0 && s.info.Signatures >= s.info.MaxSignatures {
+		// The current signature is still allowed, the key is locked after it
+		return nil
+	}
+
+	sm.schedule(s, now)
+
+	return nil
+}
+
+// Locks the account if the session has run out of signatures, or has ended
+// while signing. Called once after every successful use or useN.
+func (sm *sessionManager) release(address gethcommon.Address) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.pending[address]--
+	if sm.pending[address] > 0 {
+		return
+	}
+	delete(sm.pending, address)
+
+	s, ok := sm.sessions[address]
+	if !ok || (s.info.MaxSignatures > 0 && s.info.Signatures >= s.info.MaxSignatures) {
+		sm.end(address, true)
+	}
+}
+
+// Unlocks the account for a single signature. The returned func locks it
+// back, unless a session keeps it unlocked.
+func (sm *sessionManager) unlockOnce(address gethcommon.Address, passphrase string) (func(), error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if err := sm.ks.Unlock(address, passphrase); err != nil {
+		return nil, err
+	}
+	sm.pending[address]++
+
+	return func() {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+
+		sm.pending[address]--
+		if sm.pending[address] > 0 {
+			return
+		}
+		delete(sm.pending, address)
+
+		if _, ok := sm.sessions[address]; !ok {
+			sm.ks.Lock(address)
+		}
+	}, nil
+}
+
+func (sm *sessionManager) get(address gethcommon.Address) (SessionInfo, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	s, ok := sm.sessions[address]
+	if !ok || s.expired(time.Now()) {
+		return SessionInfo{}, false
+	}
+
+	return s.snapshot(), true
+}
+
+func (sm *sessionManager) list() []SessionInfo {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	now := time.Now()
+	infos := make([]SessionInfo, 0, len(sm.sessions))
+	for _, s := range sm.sessions {
+		if !s.expired(now) {
+			infos = append(infos, s.snapshot())
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].UnlockedAt.Before(infos[j].UnlockedAt)
+	})
+
+	return infos
+}
+
+func (sm *sessionManager) close(address gethcommon.Address) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.end(address, true)
+}
+
+func (sm *sessionManager) closeAll() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for address := range sm.sessions {
+		sm.end(address, true)
+	}
+}
+
+// Must be called with the lock held
+func (sm *sessionManager) end(address gethcommon.Address, lock bool) {
+	if s, ok := sm.sessions[address]; ok {
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		delete(sm.sessions, address)
+	}
+
+	if lock && sm.pending[address] == 0 {
+		sm.ks.Lock(address)
+	}
+}
+
+// Must be called with the lock held. Arms the timer for the closest deadline.
+func (sm *sessionManager) schedule(s *session, now time.Time) {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	deadline, ok := s.deadline()
+	if !ok {
+		return
+	}
+
+	s.timer = time.AfterFunc(deadline.Sub(now), func() {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+
+		// Skip if the session has been replaced or closed in the meantime
+		if sm.sessions[s.info.Address] != s {
+			return
+		}
+
+		now := time.Now()
+		if s.expired(now) {
+			sm.end(s.info.Address, true)
+		} else {
+			sm.schedule(s, now)
+		}
+	})
+}
+
+func (s *session) deadline() (time.Time, bool) {
+	var deadline time.Time
+
+	if s.info.IdleTimeout > 0 {
+		deadline = s.info.LastUsedAt.Add(s.info.IdleTimeout)
+	}
+	if !s.info.ExpiresAt.IsZero() && (deadline.IsZero() || s.info.ExpiresAt.Before(deadline)) {
+		deadline = s.info.ExpiresAt
+	}
+
+	return deadline, !deadline.IsZero()
+}
+
+func (s *session) snapshot() SessionInfo {
+	info := s.info
+	info.Deadline, _ = s.deadline()
+
+	return info
+}
+
+func (s *session) expired(now time.Time) bool {
+	deadline, ok := s.deadline()
+	if ok && !now.Before(deadline) {
+		return true
+	}
+
+	return s.info.MaxSignatures > 0 && s.info.Signatures >= s.info.MaxSignatures
+}
@@ -0,0 +1,198 @@
+package wallet
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func newTestSessionWallet(t *testing.T) (*WalletKeeper, gethcommon.Address) {
+	t.Helper()
+
+	wk, address := newTestWallet(t)
+	wk.opts.InsecureUnlockAllowed = true
+
+	return wk, address
+}
+
+func signTestMessage(wk *WalletKeeper, address gethcommon.Address) error {
+	_, err := wk.SignMessage([]byte("hello"), address, true)
+	return err
+}
+
+func TestSessionInsecureUnlockNotAllowed(t *testing.T) {
+	wk, address := newTestWallet(t)
+
+	if err := wk.UnlockAccountFor(address, testPassphrase, SessionOptions{}); err != InsecureUnlockNotAllowed {
+		t.Fatalf("err = %v, want InsecureUnlockNotAllowed", err)
+	}
+	if err := signTestMessage(wk, address); err != AccountLocked {
+		t.Fatalf("err = %v, want AccountLocked", err)
+	}
+}
+
+func TestSessionWrongPassphrase(t *testing.T) {
+	wk, address := newTestSessionWallet(t)
+
+	if err := wk.UnlockAccountFor(address, "wrong", SessionOptions{}); err != UnauthorizedAccess {
+		t.Fatalf("err = %v, want UnauthorizedAccess", err)
+	}
+	if _, ok := wk.Session(address); ok {
+		t.Fatal("session opened with a wrong passphrase")
+	}
+}
+
+func TestSessionMaxSignatures(t *testing.T) {
+	wk, address := newTestSessionWallet(t)
+
+	if err := wk.UnlockAccountFor(address, testPassphrase, SessionOptions{MaxSignatures: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signTestMessage(wk, address); err != nil {
+		t.Fatal(err)
+	}
+
+	info, ok := wk.Session(address)
+	if !ok || info.Signatures != 1 || info.MaxSignatures != 2 {
+		t.Fatalf("session = %+v, %v", info, ok)
+	}
+
+	if err := signTestMessage(wk, address); err != nil {
+		t.Fatal(err)
+	}
+	if err := signTestMessage(wk, address); err != AccountLocked {
+		t.Fatalf("err = %v, want AccountLocked", err)
+	}
+	if _, ok := wk.Session(address); ok {
+		t.Fatal("session still open after its last signature")
+	}
+	if _, ok := wk.ks.unlocked[address]; ok {
+		t.Fatal("key still unlocked after the session ended")
+	}
+}
+
+func TestSessionBatchSignatures(t *testing.T) {
+	wk, address := newTestSessionWallet(t)
+
+	if err := wk.UnlockAccountFor(address, testPassphrase, SessionOptions{MaxSignatures: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	chainId := big.NewInt(1)
+	txs := []*types.Transaction{
+		testTransaction(testRecipient, 1, nil),
+		testTransaction(testRecipient, 2, nil),
+		testTransaction(testRecipient, 3, nil),
+	}
+
+	// All or nothing: a batch larger than what's left uses none
+	if _, err := wk.SignTransactions(chainId, txs, address, true); err != AccountLocked {
+		t.Fatalf("err = %v, want AccountLocked", err)
+	}
+	if info, ok := wk.Session(address); !ok || info.Signatures != 0 {
+		t.Fatalf("session = %+v, %v", info, ok)
+	}
+
+	if _, err := wk.SignTransactions(chainId, txs[:2], address, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := wk.Session(address); ok {
+		t.Fatal("session still open after its last signatures")
+	}
+}
+
+// A signature in flight keeps the key unlocked even if its session ends
+func TestSessionPendingSignature(t *testing.T) {
+	wk, address := newTestSessionWallet(t)
+
+	if err := wk.UnlockAccountFor(address, testPassphrase, SessionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := wk.authorize(address, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wk.LockAccount(address)
+	if _, ok := wk.ks.unlocked[address]; !ok {
+		t.Fatal("key locked while signing")
+	}
+
+	lock()
+	if _, ok := wk.ks.unlocked[address]; ok {
+		t.Fatal("key still unlocked after signing")
+	}
+}
+
+func TestSessionDeadline(t *testing.T) {
+	wk, address := newTestSessionWallet(t)
+
+	err := wk.UnlockAccountFor(address, testPassphrase, SessionOptions{
+		Duration:    time.Hour,
+		IdleTimeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, ok := wk.Session(address)
+	if !ok {
+		t.Fatal("no session")
+	}
+	if info.IdleTimeout != time.Minute || !info.ExpiresAt.Equal(info.UnlockedAt.Add(time.Hour)) {
+		t.Fatalf("session = %+v", info)
+	}
+	if !info.Deadline.Equal(info.LastUsedAt.Add(time.Minute)) {
+		t.Fatalf("deadline = %v, want the idle timeout", info.Deadline)
+	}
+
+	// Past its deadline the session is over, even before the timer fires
+	wk.sessions.mu.Lock()
+	wk.sessions.sessions[address].info.LastUsedAt = time.Now().Add(-2 * time.Minute)
+	wk.sessions.mu.Unlock()
+
+	if _, ok := wk.Session(address); ok {
+		t.Fatal("idle session still open")
+	}
+	if err := signTestMessage(wk, address); err != AccountLocked {
+		t.Fatalf("err = %v, want AccountLocked", err)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	wk, address := newTestSessionWallet(t)
+
+	err := wk.UnlockAccountFor(address, testPassphrase, SessionOptions{Duration: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signTestMessage(wk, address); err != nil {
+		t.Fatal(err)
+	}
+
+	// Locked by the timer, without any further use
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		wk.ks.mu.Lock()
+		_, unlocked := wk.ks.unlocked[address]
+		wk.ks.mu.Unlock()
+
+		if !unlocked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("key still unlocked after the session expired")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, ok := wk.Session(address); ok {
+		t.Fatal("expired session still open")
+	}
+}
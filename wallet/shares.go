@@ -0,0 +1,266 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/0xNSHuman/dapp-tools/utils"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/tyler-smith/go-bip39"
+)
+
+type ShareFormat uint8
+
+const (
+	// 0x-less hex string
+	ShareFormatHex ShareFormat = iota
+	// Words from the BIP-39 English wordlist, 11 bits each
+	ShareFormatWords
+)
+
+const shareVersion = 1
+
+// What the shares hold
+const (
+	shareKindPrivateKey byte = iota
+	shareKindSeedEntropy
+)
+
+// A share is encoded as
+// [version][kind][split ID: 2][threshold][x][y...][checksum: 4]
+// where the checksum is the beginning of SHA-256 of everything before it.
+// The split ID prevents mixing shares of different splits.
+const (
+	shareHeaderLength   = 5
+	shareChecksumLength = 4
+)
+
+type share struct {
+	kind      byte
+	splitId   [2]byte
+	threshold byte
+	point     []byte // [x || y...] as in utils.SplitSecret
+}
+
+// Splits the account's private key (ExportModePrivateKey) or the HD seed
+// (ExportModeSeedPhrase) into n shares, any threshold of which restore it
+// through ImportModeShares. The BIP-39 passphrase of the seed, if any,
+// is not part of the shares.
+func (wk *WalletKeeper) ExportShares(
+	address gethcommon.Address,
+	mode ExportMode,
+	passphrase string,
+	n int,
+	threshold int,
+	format ShareFormat,
+) ([]string, error) {
+	var kind byte
+	var secret []byte
+
+	switch mode {
+	case ExportModePrivateKey:
+		privKey, err := wk.ExportAccount(address, ExportModePrivateKey, passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		kind, secret = shareKindPrivateKey, privKey
+	case ExportModeSeedPhrase:
+		mnemonic, err := wk.exportSeedPhrase(passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		entropy, err := bip39.EntropyFromMnemonic(string(mnemonic))
+		if err != nil {
+			return nil, InvalidSeedPhrase
+		}
+
+		kind, secret = shareKindSeedEntropy, entropy
+	default:
+		return nil, InvalidShares
+	}
+
+	points, err := utils.SplitSecret(secret, n, threshold)
+	if err != nil {
+		return nil, InvalidShares
+	}
+
+	var splitId [2]byte
+	if _, err := rand.Read(splitId[:]); err != nil {
+		return nil, err
+	}
+
+	encoded := make([]string, len(points))
+	for i, point := range points {
+		encoded[i] = encodeShare(&share{kind, splitId, byte(threshold), point}, format)
+	}
+
+	return encoded, nil
+}
+
+// Restores a private key or an HD seed from shares made by ExportShares.
+// seedPassphrase is the optional BIP-39 passphrase of a restored seed.
+// Seeds are only restored into wallets without one, others get
+// SeedAlreadyExists; import the mnemonic with ImportSeedPhrase instead.
+func (wk *WalletKeeper) ImportShares(encoded []string, seedPassphrase string, passphrase string) error {
+	if len(encoded) == 0 {
+		return InvalidShares
+	}
+
+	shares := make([]*share, len(encoded))
+	for i, s := range encoded {
+		decoded, err := decodeShare(s)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 && (decoded.kind != shares[0].kind || decoded.splitId != shares[0].splitId) {
+			return InvalidShares
+		}
+		shares[i] = decoded
+	}
+
+	if len(shares) < int(shares[0].threshold) {
+		return NotEnoughShares
+	}
+
+	points := make([][]byte, len(shares))
+	for i, s := range shares {
+		points[i] = s.point
+	}
+
+	secret, err := utils.CombineShares(points)
+	if err != nil {
+		return InvalidShares
+	}
+
+	switch shares[0].kind {
+	case shareKindPrivateKey:
+		return wk.ImportWallet(ImportModePrivateKey, secret, passphrase)
+	case shareKindSeedEntropy:
+		hasSeed, err := wk.HasSeed()
+		if err != nil {
+			return err
+		}
+		if hasSeed {
+			return SeedAlreadyExists
+		}
+
+		mnemonic, err := bip39.NewMnemonic(secret)
+		if err != nil {
+			return InvalidShares
+		}
+
+		return wk.ImportSeedPhrase(mnemonic, seedPassphrase, 0, passphrase)
+	}
+
+	return InvalidShares
+}
+
+// Input of ImportModeShares: one share per line
+func splitSharesInput(input []byte) []string {
+	var encoded []string
+
+	for _, line := range strings.Split(string(input), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			encoded = append(encoded, line)
+		}
+	}
+
+	return encoded
+}
+
+func encodeShare(s *share, format ShareFormat) string {
+	data := make([]byte, 0, shareHeaderLength+len(s.point)+shareChecksumLength)
+	data = append(data, shareVersion, s.kind, s.splitId[0], s.splitId[1], s.threshold)
+	data = append(data, s.point...)
+	checksum := sha256.Sum256(data)
+	data = append(data, checksum[:shareChecksumLength]...)
+
+	if format == ShareFormatWords {
+		return bytesToWords(data)
+	}
+
+	return hex.EncodeToString(data)
+}
+
+func decodeShare(encoded string) (*share, error) {
+	var data []byte
+	var err error
+
+	if fields := strings.Fields(encoded); len(fields) > 1 {
+		data, err = wordsToBytes(fields)
+	} else {
+		data, err = hex.DecodeString(strings.TrimPrefix(encoded, "0x"))
+	}
+	if err != nil || len(data) < shareHeaderLength+2+shareChecksumLength {
+		return nil, InvalidShares
+	}
+
+	body := data[:len(data)-shareChecksumLength]
+	checksum := sha256.Sum256(body)
+	if !bytes.Equal(checksum[:shareChecksumLength], data[len(body):]) || body[0] != shareVersion {
+		return nil, InvalidShares
+	}
+
+	return &share{
+		kind:      body[1],
+		splitId:   [2]byte{body[2], body[3]},
+		threshold: body[4],
+		point:     body[shareHeaderLength:],
+	}, nil
+}
+
+// Packs [length][data...] into 11-bit words, zero padded at the end
+func bytesToWords(data []byte) string {
+	wordList := bip39.GetWordList()
+	prefixed := append([]byte{byte(len(data))}, data...)
+
+	var words []string
+	var acc, bits uint
+
+	for _, b := range prefixed {
+		acc = acc<<8 | uint(b)
+		bits += 8
+
+		for bits >= 11 {
+			bits -= 11
+			words = append(words, wordList[(acc>>bits)&0x7ff])
+		}
+	}
+	if bits > 0 {
+		words = append(words, wordList[(acc<<(11-bits))&0x7ff])
+	}
+
+	return strings.Join(words, " ")
+}
+
+func wordsToBytes(words []string) ([]byte, error) {
+	var data []byte
+	var acc, bits uint
+
+	for _, word := range words {
+		index, ok := bip39.GetWordIndex(strings.ToLower(word))
+		if !ok {
+			return nil, InvalidShares
+		}
+
+		acc = acc<<11 | uint(index)
+		bits += 11
+
+		for bits >= 8 {
+			bits -= 8
+			data = append(data, byte(acc>>bits))
+		}
+	}
+
+	if len(data) == 0 || int(data[0]) > len(data)-1 {
+		return nil, InvalidShares
+	}
+
+	return data[1 : 1+int(data[0])], nil
+}
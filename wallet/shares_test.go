@@ -0,0 +1,102 @@
+package wallet
+
+import (
+	"strings"
+	"testing"
+)
+
+func newEmptyTestWallet(t *testing.T) *WalletKeeper {
+	t.Helper()
+
+	wk, err := NewWalletKeeperWithOptions(testUI{}, Options{Path: t.TempDir(), LightScrypt: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return wk
+}
+
+func TestSharesPrivateKey(t *testing.T) {
+	wk, address := newTestWallet(t)
+
+	for _, format := range []ShareFormat{ShareFormatHex, ShareFormatWords} {
+		shares, err := wk.ExportShares(address, ExportModePrivateKey, testPassphrase, 5, 3, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		restored := newEmptyTestWallet(t)
+
+		if err := restored.ImportShares(shares[:2], "", testPassphrase); err != NotEnoughShares {
+			t.Fatalf("err = %v, want NotEnoughShares", err)
+		}
+
+		input := strings.Join([]string{shares[4], shares[0], shares[2]}, "\n")
+		if err := restored.ImportWallet(ImportModeShares, []byte(input), testPassphrase); err != nil {
+			t.Fatal(err)
+		}
+		if !restored.HasAccount(address) {
+			t.Fatalf("format %d: account not restored", format)
+		}
+	}
+}
+
+func TestSharesMixedSplits(t *testing.T) {
+	wk, address := newTestWallet(t)
+
+	first, err := wk.ExportShares(address, ExportModePrivateKey, testPassphrase, 3, 2, ShareFormatHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := wk.ExportShares(address, ExportModePrivateKey, testPassphrase, 3, 2, ShareFormatHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = newEmptyTestWallet(t).ImportShares([]string{first[0], second[1]}, "", testPassphrase)
+	if err != InvalidShares {
+		t.Fatalf("err = %v, want InvalidShares", err)
+	}
+}
+
+func TestSharesSeed(t *testing.T) {
+	wk := newEmptyTestWallet(t)
+	if err := wk.CreateWallet(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := wk.ListAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := accounts[0].Address
+
+	shares, err := wk.ExportShares(address, ExportModeSeedPhrase, testPassphrase, 3, 2, ShareFormatWords)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newEmptyTestWallet(t)
+	if err := restored.ImportShares(shares[1:], "", testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+
+	hasSeed, err := restored.HasSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored.HasAccount(address) || !hasSeed {
+		t.Fatal("seed not restored")
+	}
+
+	// A wallet with a seed of its own keeps it
+	other, _ := newTestWallet(t)
+	if err := other.CreateWallet(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.ImportShares(shares[:2], "", testPassphrase); err != SeedAlreadyExists {
+		t.Fatalf("err = %v, want SeedAlreadyExists", err)
+	}
+	if other.HasAccount(address) {
+		t.Fatal("account of the shares imported")
+	}
+}
@@ -0,0 +1,105 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/0xNSHuman/dapp-tools/common"
+	"github.com/0xNSHuman/dapp-tools/utils"
+	"github.com/ethereum/go-ethereum/accounts"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// Signs EIP-712 typed data given in the eth_signTypedData_v4 JSON format.
+// Returns a 65-byte [R || S || V] signature with V in {27, 28}.
+func (wk *WalletKeeper) SignTypedData(
+	typedDataJSON []byte,
+	signer gethcommon.Address,
+	autosign bool,
+) ([]byte, error) {
+	if _, err := utils.TypedDataHash(typedDataJSON); err != nil {
+		return nil, InvalidTypedData
+	}
+
+	return wk.sign(AuditKindTypedData, typedDataJSON, signer, autosign, func() ([]byte, error) {
+		return wk.signer.SignData(signer, accounts.MimetypeTypedData, typedDataJSON)
+	})
+}
+
+// Signs an EIP-191 personal message (personal_sign), i.e. the keccak256 hash of
+// "\x19Ethereum Signed Message:\n" + len(message) + message.
+func (wk *WalletKeeper) SignMessage(
+	message []byte,
+	signer gethcommon.Address,
+	autosign bool,
+) ([]byte, error) {
+	return wk.sign(AuditKindMessage, message, signer, autosign, func() ([]byte, error) {
+		return wk.signer.SignData(signer, accounts.MimetypeTextPlain, message)
+	})
+}
+
+// Signs a raw 32-byte hash. Prefer SignMessage or SignTypedData when possible,
+// since a raw hash could as well be a transaction hash. For that reason
+// it's rejected with PolicyViolation while a signing policy is set.
+// Not supported by external signers.
+func (wk *WalletKeeper) SignHash(
+	hash []byte,
+	signer gethcommon.Address,
+	autosign bool,
+) ([]byte, error) {
+	if len(hash) != gethcommon.HashLength {
+		return nil, InvalidHash
+	}
+
+	if policy := wk.SigningPolicy(); policy != nil {
+		err := policy.authorizeData(AuditKindHash, hash, signer)
+		return wk.auditData(AuditKindHash, hash, signer, autosign, nil, err)
+	}
+
+	hashSigner, ok := wk.signer.(interface {
+		SignHash(account gethcommon.Address, hash []byte) ([]byte, error)
+	})
+	if !ok {
+		return nil, common.NotSupported
+	}
+
+	return wk.sign(AuditKindHash, hash, signer, autosign, func() ([]byte, error) {
+		return hashSigner.SignHash(signer, hash)
+	})
+}
+
+// Every request is checked against the signing policy
+// and recorded in the audit log under kind
+func (wk *WalletKeeper) sign(
+	kind AuditKind,
+	data []byte,
+	signer gethcommon.Address,
+	autosign bool,
+	sign func() ([]byte, error),
+) ([]byte, error) {
+	if err := wk.checkSigner(signer); err != nil {
+		return nil, err
+	}
+
+	if policy := wk.SigningPolicy(); policy != nil {
+		if err := policy.authorizeData(kind, data, signer); err != nil {
+			return wk.auditData(kind, data, signer, autosign, nil, err)
+		}
+	}
+
+	lock, err := wk.authorize(signer, autosign)
+	if err != nil {
+		return wk.auditData(kind, data, signer, autosign, nil, err)
+	}
+	defer lock()
+
+	fmt.Println("Signing with address:", signer.Hex())
+	fmt.Println()
+
+	signature, err := sign()
+	if err != nil {
+		fmt.Println(err)
+		return wk.auditData(kind, data, signer, autosign, nil, SigningFailed)
+	}
+
+	return wk.auditData(kind, data, signer, autosign, signature, nil)
+}
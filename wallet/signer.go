@@ -0,0 +1,281 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xNSHuman/dapp-tools/common"
+	"github.com/0xNSHuman/dapp-tools/utils"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Backend holding the keys. Data is given along with a Clef mime type:
+// accounts.MimetypeTextPlain for personal messages and
+// accounts.MimetypeTypedData for EIP-712 typed data JSON.
+// Signatures are 65 bytes [R || S || V] with V in {27, 28}.
+type Signer interface {
+	Accounts() ([]gethcommon.Address, error)
+	SignTx(account gethcommon.Address, tx *types.Transaction, chainId *big.Int) (*types.Transaction, error)
+	SignData(account gethcommon.Address, mimeType string, data []byte) ([]byte, error)
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								KEYSTORE SIGNER
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+// Signs with keys from a geth keystore, e.g. one shared with geth itself.
+// The wallet unlocks them like its own keys, with the passphrase from
+// its WalletUI or for an unlock session.
+type KeystoreSigner struct {
+	ks *keystore.KeyStore
+}
+
+func NewKeystoreSigner(ks *keystore.KeyStore) *KeystoreSigner {
+	return &KeystoreSigner{ks: ks}
+}
+
+func (s *KeystoreSigner) Accounts() ([]gethcommon.Address, error) {
+	accs := s.ks.Accounts()
+
+	addresses := make([]gethcommon.Address, len(accs))
+	for i, acc := range accs {
+		addresses[i] = acc.Address
+	}
+
+	return addresses, nil
+}
+
+func (s *KeystoreSigner) SignTx(
+	account gethcommon.Address,
+	tx *types.Transaction,
+	chainId *big.Int,
+) (*types.Transaction, error) {
+	return s.ks.SignTx(accounts.Account{Address: account}, tx, chainId)
+}
+
+func (s *KeystoreSigner) SignData(account gethcommon.Address, mimeType string, data []byte) ([]byte, error) {
+	return signData(s.SignHash, account, mimeType, data)
+}
+
+// Keeps the key unlocked in the keystore until Lock
+func (s *KeystoreSigner) Unlock(account gethcommon.Address, passphrase string) error {
+	err := s.ks.Unlock(accounts.Account{Address: account}, passphrase)
+	if errors.Is(err, keystore.ErrNoMatch) {
+		return AccountNotFound
+	}
+	if err != nil {
+		return UnauthorizedAccess
+	}
+
+	return nil
+}
+
+func (s *KeystoreSigner) Lock(account gethcommon.Address) {
+	s.ks.Lock(account)
+}
+
+func (s *KeystoreSigner) SignHash(account gethcommon.Address, hash []byte) ([]byte, error) {
+	signature, err := s.ks.SignHash(accounts.Account{Address: account}, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	signature[crypto.RecoveryIDOffset] += 27
+
+	return signature, nil
+}
+
+// Hashes data as its mime type requires and signs the hash
+func signData(
+	signHash func(account gethcommon.Address, hash []byte) ([]byte, error),
+	account gethcommon.Address,
+	mimeType string,
+	data []byte,
+) ([]byte, error) {
+	var hash []byte
+
+	switch mimeType {
+	case accounts.MimetypeTextPlain:
+		hash = accounts.TextHash(data)
+	case accounts.MimetypeTypedData:
+		typedDataHash, err := utils.TypedDataHash(data)
+		if err != nil {
+			return nil, InvalidTypedData
+		}
+		hash = typedDataHash
+	default:
+		return nil, common.NotSupported
+	}
+
+	return signHash(account, hash)
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								WALLET SIGNER
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+// Signs through the WalletKeeper, so the signing policy, unlock sessions
+// and the audit log apply to every signature. Accounts with an unlock
+// session are autosigned, others ask for the passphrase.
+type walletSigner struct {
+	wk *WalletKeeper
+}
+
+func (s walletSigner) Accounts() ([]gethcommon.Address, error) {
+	return s.wk.signer.Accounts()
+}
+
+func (s walletSigner) SignTx(
+	account gethcommon.Address,
+	tx *types.Transaction,
+	chainId *big.Int,
+) (*types.Transaction, error) {
+	return s.wk.SignTransaction(chainId, tx, account, s.autosign(account))
+}
+
+func (s walletSigner) SignData(account gethcommon.Address, mimeType string, data []byte) ([]byte, error) {
+	switch mimeType {
+	case accounts.MimetypeTextPlain:
+		return s.wk.SignMessage(data, account, s.autosign(account))
+	case accounts.MimetypeTypedData:
+		return s.wk.SignTypedData(data, account, s.autosign(account))
+	}
+
+	return nil, common.NotSupported
+}
+
+func (s walletSigner) autosign(account gethcommon.Address) bool {
+	_, ok := s.wk.Session(account)
+	return ok
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								EXTERNAL SIGNER
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+// Signs through an external signer speaking Clef's account_* JSON-RPC API.
+// Approval of every request is up to the external signer.
+type ExternalSigner struct {
+	client *rpc.Client
+}
+
+// Endpoint is an HTTP(S) URL or an IPC socket path, e.g. ~/.clef/clef.ipc
+func NewExternalSigner(endpoint string) (*ExternalSigner, error) {
+	client, err := rpc.Dial(endpoint)
+	if err != nil {
+		return nil, ExternalSignerUnavailable
+	}
+
+	return &ExternalSigner{client: client}, nil
+}
+
+func (s *ExternalSigner) Close() {
+	s.client.Close()
+}
+
+func (s *ExternalSigner) Accounts() ([]gethcommon.Address, error) {
+	var addresses []gethcommon.Address
+
+	if err := s.client.CallContext(context.Background(), &addresses, "account_list"); err != nil {
+		return nil, fmt.Errorf("%w: %v", ExternalSignerUnavailable, err)
+	}
+
+	return addresses, nil
+}
+
+func (s *ExternalSigner) SignTx(
+	account gethcommon.Address,
+	tx *types.Transaction,
+	chainId *big.Int,
+) (*types.Transaction, error) {
+	data := hexutil.Bytes(tx.Data())
+	args := apitypes.SendTxArgs{
+		From:  gethcommon.NewMixedcaseAddress(account),
+		Gas:   hexutil.Uint64(tx.Gas()),
+		Value: hexutil.Big(*tx.Value()),
+		Nonce: hexutil.Uint64(tx.Nonce()),
+		Data:  &data,
+	}
+
+	if to := tx.To(); to != nil {
+		mixedcaseTo := gethcommon.NewMixedcaseAddress(*to)
+		args.To = &mixedcaseTo
+	}
+	if chainId != nil {
+		args.ChainID = (*hexutil.Big)(chainId)
+	}
+
+	switch tx.Type() {
+	case types.LegacyTxType:
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+	case types.AccessListTxType:
+		accessList := tx.AccessList()
+		args.GasPrice = (*hexutil.Big)(tx.GasPrice())
+		args.AccessList = &accessList
+	default:
+		accessList := tx.AccessList()
+		args.MaxFeePerGas = (*hexutil.Big)(tx.GasFeeCap())
+		args.MaxPriorityFeePerGas = (*hexutil.Big)(tx.GasTipCap())
+		args.AccessList = &accessList
+	}
+
+	var result struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}
+
+	if err := s.client.CallContext(context.Background(), &result, "account_signTransaction", &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", SigningFailed, err)
+	}
+
+	signedTx := new(types.Transaction)
+	if err := signedTx.UnmarshalBinary(result.Raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", SigningFailed, err)
+	}
+
+	return signedTx, nil
+}
+
+func (s *ExternalSigner) SignData(account gethcommon.Address, mimeType string, data []byte) ([]byte, error) {
+	var signature hexutil.Bytes
+	var err error
+
+	switch mimeType {
+	case accounts.MimetypeTypedData:
+		typedData, parseErr := utils.ParseTypedData(data)
+		if parseErr != nil {
+			return nil, InvalidTypedData
+		}
+
+		err = s.client.CallContext(
+			context.Background(),
+			&signature,
+			"account_signTypedData",
+			account,
+			typedData,
+		)
+	default:
+		err = s.client.CallContext(
+			context.Background(),
+			&signature,
+			"account_signData",
+			mimeType,
+			account,
+			hexutil.Bytes(data),
+		)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", SigningFailed, err)
+	}
+
+	return signature, nil
+}
@@ -0,0 +1,323 @@
+package wallet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/0xNSHuman/dapp-tools/utils"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// Stand-in for Clef's account_* API, signing with a single key
+type testClef struct {
+	key *ecdsa.PrivateKey
+}
+
+func (c *testClef) List(ctx context.Context) ([]gethcommon.Address, error) {
+	return []gethcommon.Address{crypto.PubkeyToAddress(c.key.PublicKey)}, nil
+}
+
+func (c *testClef) SignTransaction(
+	ctx context.Context,
+	args apitypes.SendTxArgs,
+	methodSelector *string,
+) (map[string]interface{}, error) {
+	if args.ChainID == nil {
+		return nil, errors.New("missing chain ID")
+	}
+
+	chainId := (*big.Int)(args.ChainID)
+	signedTx, err := types.SignTx(args.ToTransaction(), types.LatestSignerForChainID(chainId), c.key)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"raw": hexutil.Bytes(raw), "tx": signedTx}, nil
+}
+
+func (c *testClef) SignData(
+	ctx context.Context,
+	contentType string,
+	address gethcommon.MixedcaseAddress,
+	data hexutil.Bytes,
+) (hexutil.Bytes, error) {
+	if contentType != accounts.MimetypeTextPlain {
+		return nil, errors.New("unsupported content type")
+	}
+
+	signature, err := crypto.Sign(accounts.TextHash(data), c.key)
+	if err != nil {
+		return nil, err
+	}
+	signature[crypto.RecoveryIDOffset] += 27
+
+	return signature, nil
+}
+
+func newTestExternalSigner(t *testing.T) (*ExternalSigner, gethcommon.Address) {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", &testClef{key}); err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	t.Cleanup(server.Stop)
+
+	signer, err := NewExternalSigner(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(signer.Close)
+
+	return signer, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+func TestExternalSignerAccounts(t *testing.T) {
+	signer, address := newTestExternalSigner(t)
+
+	addresses, err := signer.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 1 || addresses[0] != address {
+		t.Fatalf("accounts = %v, want [%v]", addresses, address)
+	}
+}
+
+func TestExternalSignerSignTx(t *testing.T) {
+	signer, address := newTestExternalSigner(t)
+
+	chainId := big.NewInt(5)
+	to := gethcommon.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	txs := map[string]*types.Transaction{
+		"legacy": types.NewTx(&types.LegacyTx{
+			Nonce:    1,
+			To:       &to,
+			Value:    big.NewInt(1),
+			Gas:      21000,
+			GasPrice: big.NewInt(10),
+		}),
+		"dynamic fee": types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainId,
+			Nonce:     2,
+			To:        &to,
+			Value:     big.NewInt(1),
+			Gas:       21000,
+			GasFeeCap: big.NewInt(10),
+			GasTipCap: big.NewInt(1),
+			Data:      []byte{1, 2, 3},
+		}),
+	}
+
+	for name, tx := range txs {
+		t.Run(name, func(t *testing.T) {
+			signedTx, err := signer.SignTx(address, tx, chainId)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if signedTx.Type() != tx.Type() || signedTx.Nonce() != tx.Nonce() {
+				t.Fatalf("signed type %d nonce %d, want type %d nonce %d",
+					signedTx.Type(), signedTx.Nonce(), tx.Type(), tx.Nonce())
+			}
+
+			from, err := types.Sender(types.LatestSignerForChainID(chainId), signedTx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != address {
+				t.Fatalf("sender = %v, want %v", from, address)
+			}
+		})
+	}
+}
+
+func TestExternalSignerSignData(t *testing.T) {
+	signer, address := newTestExternalSigner(t)
+
+	message := []byte("hello")
+	signature, err := signer.SignData(address, accounts.MimetypeTextPlain, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := utils.VerifyMessageSignature(message, signature, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Fatal("signature doesn't verify")
+	}
+
+	// Errors of the signer come back as SigningFailed
+	if _, err := signer.SignData(address, "application/octet-stream", message); !errors.Is(err, SigningFailed) {
+		t.Fatalf("err = %v, want SigningFailed", err)
+	}
+}
+
+func TestExternalSignerUnavailable(t *testing.T) {
+	httpServer := httptest.NewServer(rpc.NewServer())
+	httpServer.Close()
+
+	signer, err := NewExternalSigner(httpServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer signer.Close()
+
+	if _, err := signer.Accounts(); !errors.Is(err, ExternalSignerUnavailable) {
+		t.Fatalf("err = %v, want ExternalSignerUnavailable", err)
+	}
+}
+
+// Counts passphrase prompts
+type countingUI struct {
+	testUI
+	prompts int
+}
+
+func (ui *countingUI) EnterPassphrase() (string, error) {
+	ui.prompts++
+	return testPassphrase, nil
+}
+
+func TestWalletSignerChecksEverySignature(t *testing.T) {
+	ui := new(countingUI)
+	wk, err := NewWalletKeeperWithOptions(ui, Options{
+		Path:                  t.TempDir(),
+		LightScrypt:           true,
+		InsecureUnlockAllowed: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wk.ImportWallet(ImportModePrivateKey, crypto.FromECDSA(key), testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	policy, err := ParseSigningPolicy([]byte(`{"default": {"maxValuePerTx": "1"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wk.SetSigningPolicy(policy)
+
+	if err := wk.UnlockAccountFor(address, testPassphrase, SessionOptions{MaxSignatures: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	chainId := big.NewInt(1)
+	signer := wk.Signer()
+
+	if _, err := signer.SignTx(address, testTransaction(testRecipient, 2, nil), chainId); !errors.Is(err, PolicyViolation) {
+		t.Fatalf("err = %v, want PolicyViolation", err)
+	}
+
+	// The session's only signature, then the passphrase is asked for
+	for i := 0; i < 2; i++ {
+		if _, err := signer.SignTx(address, testTransaction(testRecipient, 1, nil), chainId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ui.prompts != 1 {
+		t.Fatalf("got %d passphrase prompts, want 1", ui.prompts)
+	}
+
+	if _, err := signer.SignData(address, accounts.MimetypeTextPlain, []byte("hello")); !errors.Is(err, PolicyViolation) {
+		t.Fatalf("err = %v, want PolicyViolation", err)
+	}
+
+	records, err := wk.VerifyAuditLog(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d audit records, want 4", len(records))
+	}
+}
+
+func TestKeystoreSignerAuthorization(t *testing.T) {
+	gethKeystore := keystore.NewKeyStore(filepath.Join(t.TempDir(), "keystore"), keystore.LightScryptN, keystore.LightScryptP)
+	account, err := gethKeystore.NewAccount(testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ui := new(countingUI)
+	wk, err := NewWalletKeeperWithOptions(ui, Options{
+		Path:                  t.TempDir(),
+		LightScrypt:           true,
+		InsecureUnlockAllowed: true,
+		Signer:                NewKeystoreSigner(gethKeystore),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chainId := big.NewInt(1)
+	tx := testTransaction(testRecipient, 1, nil)
+
+	if _, err := wk.SignTransaction(chainId, tx, account.Address, false); err != nil {
+		t.Fatal(err)
+	}
+	if ui.prompts != 1 {
+		t.Fatalf("got %d passphrase prompts, want 1", ui.prompts)
+	}
+
+	// Locked again after the signature
+	if _, err := gethKeystore.SignTx(account, tx, chainId); !errors.Is(err, keystore.ErrLocked) {
+		t.Fatalf("err = %v, want keystore.ErrLocked", err)
+	}
+
+	// Session limits apply
+	if _, err := wk.SignTransaction(chainId, tx, account.Address, true); !errors.Is(err, AccountLocked) {
+		t.Fatalf("err = %v, want AccountLocked", err)
+	}
+
+	if err := wk.UnlockAccountFor(account.Address, "wrong", SessionOptions{}); err != UnauthorizedAccess {
+		t.Fatalf("err = %v, want UnauthorizedAccess", err)
+	}
+	if err := wk.UnlockAccountFor(account.Address, testPassphrase, SessionOptions{MaxSignatures: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := wk.SignTransaction(chainId, tx, account.Address, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wk.SignTransaction(chainId, tx, account.Address, true); !errors.Is(err, AccountLocked) {
+		t.Fatalf("err = %v, want AccountLocked", err)
+	}
+	if _, err := gethKeystore.SignTx(account, tx, chainId); !errors.Is(err, keystore.ErrLocked) {
+		t.Fatalf("err = %v, want keystore.ErrLocked", err)
+	}
+}
@@ -0,0 +1,402 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Files of a wallet. Key files use the geth keystore naming,
+// see keyFileName.
+const (
+	keystoreDir      = "keystore"
+	seedFileName     = "seed.json"
+	metadataFileName = "accounts.json"
+	auditLogFileName = "audit.log"
+)
+
+// Where the wallet keeps its files. Names are relative and slash-separated,
+// e.g. "seed.json" or "keystore/UTC--...". Reading a missing file
+// returns an error matching fs.ErrNotExist.
+type Storage interface {
+	ReadFile(name string) ([]byte, error)
+
+	// Replaces the file as a whole, readers never see it half-written
+	WriteFile(name string, data []byte) error
+
+	// Returns once the data is durable
+	AppendFile(name string, data []byte) error
+
+	Remove(name string) error
+
+	// Names of the files right in dir, sorted
+	List(dir string) ([]string, error)
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								DIRECTORY STORAGE
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+// Plain files under a root directory. Keys are kept in root/keystore
+// exactly like geth does, so the directory can be shared with geth or Clef.
+type DirectoryStorage struct {
+	root string
+}
+
+func NewDirectoryStorage(root string) *DirectoryStorage {
+	return &DirectoryStorage{root: root}
+}
+
+// Path of the file on disk
+func (s *DirectoryStorage) Path(name string) string {
+	return filepath.Join(s.root, filepath.FromSlash(name))
+}
+
+func (s *DirectoryStorage) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(s.Path(name))
+}
+
+func (s *DirectoryStorage) WriteFile(name string, data []byte) error {
+	return writeFileAtomic(s.Path(name), data)
+}
+
+func (s *DirectoryStorage) AppendFile(name string, data []byte) error {
+	path := s.Path(name)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return FileSystemAccess
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return FileSystemAccess
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return FileSystemAccess
+	}
+
+	if err := file.Sync(); err != nil {
+		return FileSystemAccess
+	}
+
+	return nil
+}
+
+func (s *DirectoryStorage) Remove(name string) error {
+	return os.Remove(s.Path(name))
+}
+
+// Hidden files, e.g. temp files of writeFileAtomic, and subdirectories
+// are skipped, same as in geth's keystore
+func (s *DirectoryStorage) List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(s.Path(dir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, FileSystemAccess
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || strings.HasSuffix(entry.Name(), "~") {
+			continue
+		}
+		names = append(names, path.Join(dir, entry.Name()))
+	}
+
+	return names, nil
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								MEMORY STORAGE
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+// Keeps everything in memory, nothing is written to disk.
+// Meant for tests and ephemeral bots.
+type MemoryStorage struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string][]byte)}
+}
+
+func (s *MemoryStorage) ReadFile(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+func (s *MemoryStorage) WriteFile(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[name] = append([]byte(nil), data...)
+
+	return nil
+}
+
+func (s *MemoryStorage) AppendFile(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.files[name] = append(s.files[name], data...)
+
+	return nil
+}
+
+func (s *MemoryStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[name]; !ok {
+		return fs.ErrNotExist
+	}
+	delete(s.files, name)
+
+	return nil
+}
+
+func (s *MemoryStorage) List(dir string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return listFiles(s.files, dir), nil
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								VAULT STORAGE
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+const vaultVersion = 1
+
+// All wallet files in a single file, encrypted as a whole with AES-256-GCM
+// under a scrypt key derived from the vault passphrase. Key files inside
+// stay encrypted with their own passphrases too.
+// Every change rewrites the vault, so it suits wallets with few keys.
+type VaultStorage struct {
+	path string
+
+	mu     sync.Mutex
+	files  map[string][]byte
+	key    []byte
+	params vaultKDFParams
+}
+
+type vaultKDFParams struct {
+	N     int           `json:"n"`
+	R     int           `json:"r"`
+	P     int           `json:"p"`
+	DKLen int           `json:"dklen"`
+	Salt  hexutil.Bytes `json:"salt"`
+}
+
+type vaultFile struct {
+	Version    int            `json:"version"`
+	KDFParams  vaultKDFParams `json:"kdfparams"`
+	Nonce      hexutil.Bytes  `json:"nonce"`
+	Ciphertext hexutil.Bytes  `json:"ciphertext"`
+}
+
+// Opens the vault at path, or prepares a new one that is created on the first
+// write. Zero scrypt parameters fall back to the keystore standard ones.
+// Returns UnauthorizedAccess if the passphrase doesn't match.
+func OpenVaultStorage(path string, passphrase string, scryptN int, scryptP int) (*VaultStorage, error) {
+	s := &VaultStorage{path: path, files: make(map[string][]byte)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if scryptN == 0 || scryptP == 0 {
+			scryptN, scryptP = keystore.StandardScryptN, keystore.StandardScryptP
+		}
+
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+
+		s.params = vaultKDFParams{N: scryptN, R: 8, P: scryptP, DKLen: 32, Salt: salt}
+		if s.key, err = s.params.deriveKey(passphrase); err != nil {
+			return nil, err
+		}
+
+		return s, nil
+	}
+	if err != nil {
+		return nil, FileSystemAccess
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != vaultVersion {
+		return nil, InvalidVault
+	}
+
+	s.params = file.KDFParams
+	if s.key, err = s.params.deriveKey(passphrase); err != nil {
+		return nil, InvalidVault
+	}
+
+	gcm, err := newVaultCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, UnauthorizedAccess
+	}
+
+	if err := json.Unmarshal(plaintext, &s.files); err != nil {
+		return nil, InvalidVault
+	}
+
+	return s, nil
+}
+
+func (s *VaultStorage) ReadFile(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+func (s *VaultStorage) WriteFile(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.change(name, append([]byte(nil), data...))
+}
+
+func (s *VaultStorage) AppendFile(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.files[name]
+	appended := make([]byte, 0, len(existing)+len(data))
+	appended = append(append(appended, existing...), data...)
+
+	return s.change(name, appended)
+}
+
+func (s *VaultStorage) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.files[name]; !ok {
+		return fs.ErrNotExist
+	}
+
+	return s.change(name, nil)
+}
+
+func (s *VaultStorage) List(dir string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return listFiles(s.files, dir), nil
+}
+
+// Must be called with the lock held. Writes the vault with the file replaced,
+// or removed if data is nil, and only then applies the change in memory.
+func (s *VaultStorage) change(name string, data []byte) error {
+	files := make(map[string][]byte, len(s.files)+1)
+	for existingName, existingData := range s.files {
+		files[existingName] = existingData
+	}
+
+	if data == nil {
+		delete(files, name)
+	} else {
+		files[name] = data
+	}
+
+	if err := s.save(files); err != nil {
+		return err
+	}
+	s.files = files
+
+	return nil
+}
+
+func (s *VaultStorage) save(files map[string][]byte) error {
+	plaintext, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := newVaultCipher(s.key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(vaultFile{
+		Version:    vaultVersion,
+		KDFParams:  s.params,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data)
+}
+
+func (params vaultKDFParams) deriveKey(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), params.Salt, params.N, params.R, params.P, params.DKLen)
+}
+
+func newVaultCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func listFiles(files map[string][]byte, dir string) []string {
+	var names []string
+
+	for name := range files {
+		if path.Dir(name) == path.Clean(dir) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,232 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+)
+
+func openTestVault(t *testing.T, path string, passphrase string) *VaultStorage {
+	t.Helper()
+
+	vault, err := OpenVaultStorage(path, passphrase, keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return vault
+}
+
+func TestVaultStorageRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+
+	vault := openTestVault(t, path, "vault")
+
+	// Nothing is written until the first change
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("stat = %v, want not exist", err)
+	}
+
+	if err := vault.WriteFile("keystore/a", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.AppendFile("keystore/a", []byte(" second")); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.WriteFile("keystore/b", []byte("removed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Remove("keystore/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("first")) {
+		t.Fatal("vault file holds plaintext")
+	}
+
+	reopened := openTestVault(t, path, "vault")
+
+	content, err := reopened.ReadFile("keystore/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "first second" {
+		t.Fatalf("content = %q", content)
+	}
+	if _, err := reopened.ReadFile("keystore/b"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("removed file: err = %v", err)
+	}
+}
+
+func TestVaultStorageWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+
+	vault := openTestVault(t, path, "vault")
+	if err := vault.WriteFile("seed", []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenVaultStorage(path, "wrong", 0, 0); err != UnauthorizedAccess {
+		t.Fatalf("err = %v, want UnauthorizedAccess", err)
+	}
+}
+
+func TestVaultStorageMalformed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	if err := os.WriteFile(path, []byte("not a vault"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenVaultStorage(path, "vault", 0, 0); err != InvalidVault {
+		t.Fatalf("err = %v, want InvalidVault", err)
+	}
+}
+
+func TestVaultStorageWallet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	opts := Options{Path: t.TempDir(), LightScrypt: true, Storage: openTestVault(t, path, "vault")}
+
+	wk, err := NewWalletKeeperWithOptions(nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wk.CreateWallet("pw"); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Storage = openTestVault(t, path, "vault")
+	reopened, err := NewWalletKeeperWithOptions(nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasSeed, err := reopened.HasSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.NumberOfAccounts() != 1 || !hasSeed {
+		t.Fatalf("reopened wallet has %d accounts, seed %v", reopened.NumberOfAccounts(), hasSeed)
+	}
+}
+
+func TestVaultStorageAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.json")
+	opts := Options{Path: t.TempDir(), LightScrypt: true, Storage: openTestVault(t, path, "vault")}
+
+	wk, err := NewWalletKeeperWithOptions(testUI{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wk.CreateWallet(testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	address, err := wk.accountAt(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vault, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := wk.SignMessage([]byte("hello"), address, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Signatures leave the vault alone
+	signed, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(signed, vault) {
+		t.Fatal("vault rewritten by signatures")
+	}
+
+	if wk.AuditLogPath() != path+".audit.log" {
+		t.Fatalf("audit log at %s", wk.AuditLogPath())
+	}
+	if records, err := VerifyAuditLog(wk.AuditLogPath(), nil); err != nil || len(records) != 3 {
+		t.Fatalf("got %d records, err = %v", len(records), err)
+	}
+}
+
+// Counts reads of key files
+type countingStorage struct {
+	*MemoryStorage
+	keyReads int
+}
+
+func (s *countingStorage) ReadFile(name string) ([]byte, error) {
+	if strings.HasPrefix(name, keystoreDir+"/") {
+		s.keyReads++
+	}
+
+	return s.MemoryStorage.ReadFile(name)
+}
+
+func TestKeyStoreIndex(t *testing.T) {
+	storage := &countingStorage{MemoryStorage: NewMemoryStorage()}
+	wk, err := NewWalletKeeperWithOptions(testUI{}, Options{Path: t.TempDir(), LightScrypt: true, Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := wk.CreateWallet(testPassphrase); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addresses, err := wk.ks.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 2 {
+		t.Fatalf("got %d accounts, want 2", len(addresses))
+	}
+
+	// Known key files aren't read again
+	storage.keyReads = 0
+	for i := 0; i < 5; i++ {
+		if !wk.HasAccount(addresses[0]) || !wk.HasAccount(addresses[1]) {
+			t.Fatal("account missing")
+		}
+	}
+	if storage.keyReads != 0 {
+		t.Fatalf("%d key file reads, want 0", storage.keyReads)
+	}
+
+	// Files added or removed behind the wallet's back are noticed
+	names, err := storage.List(keystoreDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyJSON, err := storage.MemoryStorage.ReadFile(names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Remove(names[0]); err != nil {
+		t.Fatal(err)
+	}
+	if wk.NumberOfAccounts() != 1 {
+		t.Fatal("removed key file still listed")
+	}
+
+	if err := storage.WriteFile(names[0], keyJSON); err != nil {
+		t.Fatal(err)
+	}
+	if wk.NumberOfAccounts() != 2 {
+		t.Fatal("added key file not listed")
+	}
+}
@@ -14,6 +14,30 @@ const (
 	InvalidPrivateKey
 	InvalidSeedPhrase
 	SigningFailed
+	SeedNotFound
+	InvalidProfile
+	InsecureUnlockNotAllowed
+	InvalidTypedData
+	InvalidHash
+	InvalidPolicy
+	PolicyViolation
+	ExternalSignerUnavailable
+	AccountLocked
+	InvalidBackup
+	InvalidShares
+	NotEnoughShares
+	AuditLogFailed
+	InvalidAuditLog
+	AccountAlreadyExists
+	WatchOnlyAccount
+	InvalidExtendedKey
+	RequestRejected
+	InvalidVault
+	InvalidDerivationPath
+	InvalidKeyFile
+	InvalidPresaleWallet
+	DecryptionFailed
+	SeedAlreadyExists
 )
 
 func (e WalletError) Error() string {
@@ -30,6 +54,54 @@ func (e WalletError) Error() string {
 		return "Invalid seed phrase"
 	case SigningFailed:
 		return "Transaction signing failed"
+	case SeedNotFound:
+		return "HD seed not found"
+	case InvalidProfile:
+		return "Invalid wallet profile name"
+	case InsecureUnlockNotAllowed:
+		return "Account unlocking is not allowed"
+	case InvalidTypedData:
+		return "Invalid typed data"
+	case InvalidHash:
+		return "Invalid hash"
+	case InvalidPolicy:
+		return "Invalid signing policy"
+	case PolicyViolation:
+		return "Signing policy violation"
+	case ExternalSignerUnavailable:
+		return "External signer unavailable"
+	case AccountLocked:
+		return "Account is locked"
+	case InvalidBackup:
+		return "Invalid backup"
+	case InvalidShares:
+		return "Invalid secret shares"
+	case NotEnoughShares:
+		return "Not enough secret shares"
+	case AuditLogFailed:
+		return "Can't write the audit log"
+	case InvalidAuditLog:
+		return "Audit log verification failed"
+	case AccountAlreadyExists:
+		return "Account already exists"
+	case WatchOnlyAccount:
+		return "Watch-only account can't sign"
+	case InvalidExtendedKey:
+		return "Invalid extended public key"
+	case RequestRejected:
+		return "Request rejected"
+	case InvalidVault:
+		return "Invalid vault file"
+	case InvalidDerivationPath:
+		return "Invalid derivation path"
+	case InvalidKeyFile:
+		return "Invalid keystore file"
+	case InvalidPresaleWallet:
+		return "Invalid presale wallet file"
+	case DecryptionFailed:
+		return "Decryption failed"
+	case SeedAlreadyExists:
+		return "Wallet already has an HD seed"
 	default:
 		return "Unknown"
 	}
@@ -40,6 +112,16 @@ type ImportMode uint8
 const (
 	ImportModeSeedPhrase ImportMode = iota
 	ImportModePrivateKey
+	// Newline-separated shares made by ExportShares
+	ImportModeShares
+	// V3 keystore file, decrypted with the passphrase and kept encrypted with it
+	ImportModeKeystoreJSON
+	// 0x-prefixed hex string
+	ImportModeHexPrivateKey
+	// Path of a geth or Clef keystore directory
+	ImportModeKeystoreDirectory
+	// Ethereum presale wallet file, the passphrase is the presale one
+	ImportModePresaleWallet
 )
 
 type ExportMode uint8
@@ -47,4 +129,6 @@ type ExportMode uint8
 const (
 	ExportModeSeedPhrase ExportMode = iota
 	ExportModePrivateKey
+	// V3 keystore file, see ExportKeyJSON
+	ExportModeKeystoreJSON
 )
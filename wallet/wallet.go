@@ -1,52 +1,116 @@
 package wallet
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"math/big"
-	"os"
-	"path/filepath"
+	"strings"
+	"sync"
 
 	"github.com/0xNSHuman/dapp-tools/common"
-	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	gethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/tyler-smith/go-bip32"
 )
 
 type WalletUI interface {
 	EnterPassphrase() (string, error)
+	ApproveRequest(request *ApprovalRequest) (bool, error)
 }
 
 type WalletKeeper struct {
-	ks *keystore.KeyStore
-	am *accounts.Manager
+	ks *keyStore
 	ui WalletUI
+
+	opts     Options
+	metadata *metadataStore
+	policyMu sync.Mutex
+	policy   *SigningPolicy
+	signer   Signer
+	sessions *sessionManager
+	audit    *auditLog
 }
 
 func NewWalletKeeper(ui WalletUI, autoUnlock bool) (*WalletKeeper, error) {
-	userHomeDir, err := os.UserHomeDir()
+	opts := DefaultOptions()
+	opts.InsecureUnlockAllowed = autoUnlock
+
+	return NewWalletKeeperWithOptions(ui, opts)
+}
+
+func NewWalletKeeperWithOptions(ui WalletUI, opts Options) (*WalletKeeper, error) {
+	opts, err := opts.resolve()
 	if err != nil {
-		return nil, FileSystemAccess
+		return nil, err
+	}
+
+	ks := newKeyStore(opts.Storage, opts.ScryptN, opts.ScryptP)
+
+	var signer Signer = ks
+	if opts.Signer != nil {
+		signer = opts.Signer
+	}
+
+	// Sessions of a KeystoreSigner unlock keys in its own keystore
+	var unlocker keyUnlocker = ks
+	if signerUnlocker, ok := opts.Signer.(keyUnlocker); ok {
+		unlocker = signerUnlocker
 	}
 
-	keystorePath := filepath.Join(userHomeDir, "evm", "wallet", "keystore")
+	metadata, err := loadMetadataStore(opts.Storage)
+	if err != nil {
+		return nil, err
+	}
 
-	ks := keystore.NewKeyStore(keystorePath, keystore.StandardScryptN, keystore.StandardScryptP)
-	am := accounts.NewManager(&accounts.Config{InsecureUnlockAllowed: autoUnlock}, ks)
+	audit, err := loadAuditLog(opts.auditLogStorage())
+	if err != nil {
+		return nil, err
+	}
 
 	return &WalletKeeper{
-		ks: ks,
-		am: am,
-		ui: ui,
+		ks:       ks,
+		ui:       ui,
+		opts:     opts,
+		metadata: metadata,
+		signer:   signer,
+		sessions: newSessionManager(unlocker),
+		audit:    audit,
 	}, nil
 }
 
+func (wk *WalletKeeper) Path() string {
+	return wk.opts.Path
+}
+
+func (wk *WalletKeeper) Profile() string {
+	return wk.opts.Profile
+}
+
+// Creates the next HD account. A new BIP-39 mnemonic is generated and stored
+// on first use; it can be backed up later with ExportModeSeedPhrase.
 func (wk *WalletKeeper) CreateWallet(passphrase string) error {
-	_, error := wk.ks.NewAccount(passphrase)
+	hasSeed, err := wk.HasSeed()
+	if err != nil {
+		return err
+	}
+
+	if !hasSeed {
+		mnemonic, err := NewMnemonic()
+		if err != nil {
+			return err
+		}
 
-	return error
+		return wk.ImportSeedPhrase(mnemonic, "", 0, passphrase)
+	}
+
+	file, err := wk.readSeedFile()
+	if err != nil {
+		return err
+	}
+
+	_, err = wk.DeriveAccount(file.NextIndex, passphrase)
+	return err
 }
 
 func (wk *WalletKeeper) ImportWallet(mode ImportMode, input []byte, passphrase string) error {
@@ -57,131 +121,333 @@ func (wk *WalletKeeper) ImportWallet(mode ImportMode, input []byte, passphrase s
 			return InvalidPrivateKey
 		}
 
-		_, err = wk.ks.ImportECDSA(privKey, passphrase)
-		if err != nil {
-			return err
-		}
+		_, err = wk.importKey(privKey, passphrase, "")
+		return err
 	case ImportModeSeedPhrase:
-		rootKey, err := bip32.NewMasterKey(input)
-		if err != nil {
-			return InvalidSeedPhrase
-		}
+		return wk.ImportSeedPhrase(string(input), "", 0, passphrase)
+	case ImportModeShares:
+		return wk.ImportShares(splitSharesInput(input), "", passphrase)
+	case ImportModeKeystoreJSON:
+		_, err := wk.ImportKeyJSON(input, passphrase, passphrase)
+		return err
+	case ImportModeHexPrivateKey:
+		_, err := wk.ImportHexKey(string(input), passphrase)
+		return err
+	case ImportModeKeystoreDirectory:
+		_, err := wk.ImportKeystoreDirectory(strings.TrimSpace(string(input)))
+		return err
+	case ImportModePresaleWallet:
+		_, err := wk.ImportPresaleWallet(input, passphrase, passphrase)
+		return err
+	}
 
-		privKey, err := rootKey.NewChildKey(0)
-		if err != nil {
+	return common.NotSupported
+}
+
+// Imports the account at m/44'/60'/0'/0/index of a BIP-39 mnemonic.
+// seedPassphrase is the optional BIP-39 passphrase ("25th word").
+// If no HD seed is stored yet, the mnemonic becomes the wallet's HD seed,
+// otherwise only the derived key is imported.
+func (wk *WalletKeeper) ImportSeedPhrase(
+	mnemonic string,
+	seedPassphrase string,
+	index uint32,
+	passphrase string,
+) error {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return err
+	}
+
+	hasSeed, err := wk.HasSeed()
+	if err != nil {
+		return err
+	}
+
+	if !hasSeed {
+		if err := wk.storeSeed(mnemonic, seedPassphrase, passphrase); err != nil {
 			return err
 		}
 
-		return wk.ImportWallet(ImportModePrivateKey, []byte(privKey.String()), passphrase)
+		_, err = wk.DeriveAccount(index, passphrase)
+		return err
 	}
 
-	return nil
+	_, err = wk.importDerivedKey(mnemonic, seedPassphrase, index, passphrase)
+	return err
 }
 
 func (wk *WalletKeeper) ExportWallet(index int, mode ExportMode, passphrase string) ([]byte, error) {
-	accs := wk.ks.Accounts()
-	if len(accs) <= index {
-		return nil, AccountNotFound
+	address, err := wk.accountAt(index)
+	if err != nil {
+		return nil, err
 	}
 
-	// TODO: Require a pwd change?
-	keyJSON, err := wk.ks.Export(accs[index], passphrase, passphrase)
-	if err != nil {
+	return wk.ExportAccount(address, mode, passphrase)
+}
+
+func (wk *WalletKeeper) ExportAccount(address gethcommon.Address, mode ExportMode, passphrase string) ([]byte, error) {
+	if err := wk.findAccount(address); err != nil {
 		return nil, err
 	}
 
 	switch mode {
 	case ExportModePrivateKey:
-		key, err := keystore.DecryptKey(keyJSON, passphrase)
+		// TODO: Require a pwd change?
+		key, err := wk.ks.decrypt(address, passphrase)
 		if err != nil {
 			return nil, err
 		}
 
 		return crypto.FromECDSA(key.PrivateKey), nil
 	case ExportModeSeedPhrase:
-		return nil, common.NotSupported
+		return wk.exportSeedPhrase(passphrase)
+	case ExportModeKeystoreJSON:
+		return wk.ExportKeyJSON(address, passphrase, passphrase, 0, 0)
 	}
 
 	panic(common.NotSupported)
 }
 
+// Returns the key as a V3 keystore file encrypted with newPassphrase,
+// e.g. to move it to MetaMask or another machine. Zero scrypt parameters
+// fall back to the keystore standard ones, which any wallet can open.
+func (wk *WalletKeeper) ExportKeyJSON(
+	address gethcommon.Address,
+	passphrase string,
+	newPassphrase string,
+	scryptN int,
+	scryptP int,
+) ([]byte, error) {
+	if err := wk.findAccount(address); err != nil {
+		return nil, err
+	}
+
+	if scryptN == 0 || scryptP == 0 {
+		scryptN, scryptP = keystore.StandardScryptN, keystore.StandardScryptP
+	}
+
+	key, err := wk.ks.decrypt(address, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key.PrivateKey)
+
+	return keystore.EncryptKey(key, newPassphrase, scryptN, scryptP)
+}
+
 func (wk *WalletKeeper) NumberOfAccounts() int {
-	return len(wk.ks.Accounts())
+	addresses, _ := wk.ks.Accounts()
+	return len(addresses)
+}
+
+func (wk *WalletKeeper) HasAccount(address gethcommon.Address) bool {
+	return wk.ks.HasAddress(address)
 }
 
 func (wk *WalletKeeper) PublicKey(index int) (string, error) {
-	accs := wk.ks.Accounts()
-	if len(accs) <= index {
-		return "", AccountNotFound
+	address, err := wk.accountAt(index)
+	if err != nil {
+		return "", err
 	}
 
-	return accs[index].Address.Hex(), nil
+	return address.Hex(), nil
 }
 
 func (wk *WalletKeeper) Unlock(index int, passphrase string) error {
-	accs := wk.ks.Accounts()
-	if len(accs) <= index {
-		return AccountNotFound
+	address, err := wk.accountAt(index)
+	if err != nil {
+		return err
+	}
+
+	return wk.UnlockAccount(address, passphrase)
+}
+
+// Unlocks the account until it's locked explicitly
+func (wk *WalletKeeper) UnlockAccount(address gethcommon.Address, passphrase string) error {
+	return wk.UnlockAccountFor(address, passphrase, SessionOptions{})
+}
+
+// Re-encrypts the key file with a new passphrase. The file is replaced
+// atomically, so it's never left half-written.
+func (wk *WalletKeeper) ChangePassphrase(
+	address gethcommon.Address,
+	passphrase string,
+	newPassphrase string,
+) error {
+	if err := wk.findAccount(address); err != nil {
+		return err
 	}
 
-	return wk.ks.TimedUnlock(accs[index], passphrase, 0)
+	return wk.ks.Update(address, passphrase, newPassphrase)
 }
 
+// Signs any transaction type for chainId. Legacy transactions get
+// EIP-155 replay protection, typed ones must have the same chain ID.
 func (wk *WalletKeeper) SignTransaction(
 	chainId *big.Int,
 	tx *types.Transaction,
 	signer gethcommon.Address,
 	autosign bool,
 ) (*types.Transaction, error) {
-	var signerAcc accounts.Account = accounts.Account{}
-
-	for _, acc := range wk.ks.Accounts() {
-		if acc.Address == signer {
-			signerAcc = acc
-		}
-	}
-	if signerAcc == (accounts.Account{}) {
-		return nil, AccountNotFound
+	if err := wk.checkSigner(signer); err != nil {
+		return nil, err
 	}
 
-	if !autosign {
-		passphrase, err := wk.ui.EnterPassphrase()
+	var err error
+	release := func() {}
+	if policy := wk.SigningPolicy(); policy != nil {
+		release, err = policy.authorize(chainId, tx, signer)
 		if err != nil {
-			return nil, err
+			return wk.auditTransaction(chainId, tx, signer, autosign, nil, err)
 		}
+	}
 
-		err = wk.ks.Unlock(signerAcc, passphrase)
-		if err != nil {
-			return nil, UnauthorizedAccess
-		}
+	lock, err := wk.authorize(signer, autosign)
+	if err != nil {
+		release()
+		return wk.auditTransaction(chainId, tx, signer, autosign, nil, err)
 	}
+	defer lock()
 
-	fmt.Println("Signing with address:", signerAcc.Address.Hex())
+	fmt.Println("Signing with address:", signer.Hex())
 	fmt.Println()
 
-	signedTx, err := wk.ks.SignTx(signerAcc, tx, chainId)
+	signedTx, err := wk.signer.SignTx(signer, tx, chainId)
 	if err != nil {
 		fmt.Println(err)
-		return nil, SigningFailed
+		release()
+		return wk.auditTransaction(chainId, tx, signer, autosign, nil, SigningFailed)
 	}
 
-	if !autosign {
-		wk.ks.Lock((signerAcc).Address)
+	signedTx, err = wk.auditTransaction(chainId, tx, signer, autosign, signedTx, nil)
+	if err != nil {
+		release()
+		return nil, err
 	}
 
 	return signedTx, nil
 }
 
 func (wk *WalletKeeper) DeleteWallet(index int, passphrase string) error {
-	accs := wk.ks.Accounts()
-	if len(accs) <= index {
-		return AccountNotFound
+	address, err := wk.accountAt(index)
+	if err != nil {
+		return err
+	}
+
+	return wk.DeleteAccount(address, passphrase)
+}
+
+func (wk *WalletKeeper) DeleteAccount(address gethcommon.Address, passphrase string) error {
+	if err := wk.findAccount(address); err != nil {
+		return err
 	}
 
-	err := wk.ks.Delete(accs[index], passphrase)
+	wk.sessions.close(address)
+
+	if err := wk.ks.Delete(address, passphrase); err != nil {
+		return err
+	}
+
+	return wk.metadata.remove(address)
+}
+
+func (wk *WalletKeeper) importKey(
+	privKey *ecdsa.PrivateKey,
+	passphrase string,
+	derivationPath string,
+) (gethcommon.Address, error) {
+	address, err := wk.ks.ImportECDSA(privKey, passphrase)
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	if err := wk.metadata.add(address, derivationPath); err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	return address, nil
+}
+
+// Signs through the wallet with the signer in use: the local keystore,
+// or the one from the options. Signatures are checked and recorded
+// like those of SignTransaction, SignMessage and SignTypedData.
+func (wk *WalletKeeper) Signer() Signer {
+	return walletSigner{wk}
+}
+
+// External signers approve every request themselves,
+// so the wallet neither asks for passphrases nor keeps sessions for them
+func (wk *WalletKeeper) signerApproves() bool {
+	_, ok := wk.signer.(*ExternalSigner)
+	return ok
+}
+
+func (wk *WalletKeeper) checkSigner(address gethcommon.Address) error {
+	addresses, err := wk.signer.Accounts()
 	if err != nil {
 		return err
 	}
 
+	for _, signerAddress := range addresses {
+		if signerAddress == address {
+			return nil
+		}
+	}
+
+	if wk.IsWatchOnly(address) {
+		return WatchOnlyAccount
+	}
+
+	return AccountNotFound
+}
+
+// With autosign, takes a signature from the account's unlock session.
+// Otherwise asks for the passphrase and unlocks the account for one signature.
+// The returned func must be called once signing is done.
+// External signers do their own approval.
+func (wk *WalletKeeper) authorize(address gethcommon.Address, autosign bool) (func(), error) {
+	if wk.signerApproves() {
+		return func() {}, nil
+	}
+
+	if autosign {
+		if err := wk.sessions.use(address); err != nil {
+			return nil, err
+		}
+
+		return func() { wk.sessions.release(address) }, nil
+	}
+
+	if err := wk.checkSigner(address); err != nil {
+		return nil, err
+	}
+
+	passphrase, err := wk.ui.EnterPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	return wk.sessions.unlockOnce(address, passphrase)
+}
+
+func (wk *WalletKeeper) accountAt(index int) (gethcommon.Address, error) {
+	addresses, err := wk.ks.Accounts()
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+
+	if index < 0 || len(addresses) <= index {
+		return gethcommon.Address{}, AccountNotFound
+	}
+
+	return addresses[index], nil
+}
+
+// Checks that the account has a key in the wallet's keystore
+func (wk *WalletKeeper) findAccount(address gethcommon.Address) error {
+	if !wk.ks.HasAddress(address) {
+		return AccountNotFound
+	}
+
 	return nil
 }
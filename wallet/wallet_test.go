@@ -0,0 +1,41 @@
+package wallet
+
+import (
+	"testing"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testPassphrase = "pw"
+
+// Enters testPassphrase and approves every request
+type testUI struct{}
+
+func (testUI) EnterPassphrase() (string, error) {
+	return testPassphrase, nil
+}
+
+func (testUI) ApproveRequest(request *ApprovalRequest) (bool, error) {
+	return true, nil
+}
+
+// Wallet in a temporary directory with a single imported key
+func newTestWallet(t *testing.T) (*WalletKeeper, gethcommon.Address) {
+	t.Helper()
+
+	wk, err := NewWalletKeeperWithOptions(testUI{}, Options{Path: t.TempDir(), LightScrypt: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wk.ImportWallet(ImportModePrivateKey, crypto.FromECDSA(key), testPassphrase); err != nil {
+		t.Fatal(err)
+	}
+
+	return wk, crypto.PubkeyToAddress(key.PublicKey)
+}
@@ -0,0 +1,91 @@
+package wallet
+
+import (
+	"fmt"
+	"time"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip32"
+)
+
+// Adds an address the wallet has no key for. Watch-only accounts are listed
+// along with the signer accounts and can be used as the sender of
+// unsigned transactions, e.g. with utils.EncodeTransaction,
+// but any signing request for them fails with WatchOnlyAccount.
+func (wk *WalletKeeper) AddWatchOnlyAccount(address gethcommon.Address, label string) error {
+	if wk.isSigner(address) {
+		return AccountAlreadyExists
+	}
+
+	return wk.metadata.update(address, func(info *AccountInfo) {
+		if info.CreatedAt.IsZero() {
+			info.CreatedAt = time.Now().UTC()
+		}
+		info.WatchOnly = true
+		if label != "" {
+			info.Label = label
+		}
+	})
+}
+
+// Adds watch-only accounts for the children 0..count-1 of an extended public
+// key (xpub). Children are derived right under the given key, so for
+// the standard path it should be the key of m/44'/60'/0'/0.
+// Calling it again with a larger count adds the following children.
+func (wk *WalletKeeper) ImportExtendedPublicKey(xpub string, count uint32) ([]gethcommon.Address, error) {
+	key, err := bip32.B58Deserialize(xpub)
+	if err != nil || key.IsPrivate {
+		return nil, InvalidExtendedKey
+	}
+
+	addresses := make([]gethcommon.Address, 0, count)
+
+	for index := uint32(0); index < count; index++ {
+		child, err := key.NewChildKey(index)
+		if err != nil {
+			return nil, InvalidExtendedKey
+		}
+
+		pubKey, err := crypto.DecompressPubkey(child.Key)
+		if err != nil {
+			return nil, InvalidExtendedKey
+		}
+
+		address := crypto.PubkeyToAddress(*pubKey)
+		if !wk.isSigner(address) {
+			err = wk.metadata.update(address, func(info *AccountInfo) {
+				if info.CreatedAt.IsZero() {
+					info.CreatedAt = time.Now().UTC()
+				}
+				info.WatchOnly = true
+				info.ExtendedKey = xpub
+				info.DerivationPath = fmt.Sprintf("M/%d", index)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
+
+func (wk *WalletKeeper) RemoveWatchOnlyAccount(address gethcommon.Address) error {
+	if !wk.IsWatchOnly(address) {
+		return AccountNotFound
+	}
+
+	return wk.metadata.remove(address)
+}
+
+func (wk *WalletKeeper) IsWatchOnly(address gethcommon.Address) bool {
+	info, ok := wk.metadata.get(address)
+	return ok && info.WatchOnly
+}
+
+func (wk *WalletKeeper) isSigner(address gethcommon.Address) bool {
+	return wk.checkSigner(address) == nil
+}
@@ -0,0 +1,407 @@
+package walletrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/0xNSHuman/dapp-tools/client"
+	"github.com/0xNSHuman/dapp-tools/utils"
+	"github.com/0xNSHuman/dapp-tools/wallet"
+	"github.com/ethereum/go-ethereum"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// EIP-1193 provider error codes
+const (
+	errCodeUserRejected = 4001
+	errCodeUnauthorized = 4100
+
+	// geth's code for reverted calls, the revert data is the error data
+	errCodeReverted = 3
+)
+
+type rpcError struct {
+	code int
+	err  error
+}
+
+func (e *rpcError) Error() string {
+	return e.err.Error()
+}
+
+func (e *rpcError) ErrorCode() int {
+	return e.code
+}
+
+func (e *rpcError) Unwrap() error {
+	return e.err
+}
+
+// Same as geth's error for reverted calls
+type revertError struct {
+	err  error
+	data []byte
+}
+
+func (e *revertError) Error() string {
+	return e.err.Error()
+}
+
+func (e *revertError) ErrorCode() int {
+	return errCodeReverted
+}
+
+func (e *revertError) ErrorData() interface{} {
+	return hexutil.Encode(e.data)
+}
+
+func (e *revertError) Unwrap() error {
+	return e.err
+}
+
+// Wallet errors that dapps are expected to handle get their EIP-1193 codes,
+// reverted calls keep their revert data
+func toRPCError(err error) error {
+	var clientErr *client.RPCError
+	if errors.As(err, &clientErr) && clientErr.Revert != nil && len(clientErr.Revert.Data) > 0 {
+		return &revertError{err, clientErr.Revert.Data}
+	}
+
+	switch {
+	case errors.Is(err, wallet.RequestRejected):
+		return &rpcError{errCodeUserRejected, err}
+	case errors.Is(err, wallet.AccountNotFound), errors.Is(err, wallet.WatchOnlyAccount):
+		return &rpcError{errCodeUnauthorized, err}
+	}
+
+	return err
+}
+
+// Same fields as in geth's eth_sendTransaction. Missing nonce, gas and fees
+// are filled in by the client. Transactions with gasPrice are legacy ones,
+// as are all transactions on chains without London.
+type TransactionArgs struct {
+	From                 gethcommon.Address  `json:"from"`
+	To                   *gethcommon.Address `json:"to"`
+	Gas                  *hexutil.Uint64     `json:"gas"`
+	GasPrice             *hexutil.Big        `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big        `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big        `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big        `json:"value"`
+	Nonce                *hexutil.Uint64     `json:"nonce"`
+	Data                 *hexutil.Bytes      `json:"data"`
+	Input                *hexutil.Bytes      `json:"input"`
+	ChainID              *hexutil.Big        `json:"chainId,omitempty"`
+}
+
+type SignTransactionResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								ETH NAMESPACE
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+type ethAPI struct {
+	s *Server
+}
+
+// Only accounts that can sign, watch-only ones are left out
+func (api *ethAPI) Accounts() ([]gethcommon.Address, error) {
+	return api.s.wk.Signer().Accounts()
+}
+
+func (api *ethAPI) ChainId() (*hexutil.Big, error) {
+	chainId, err := api.s.client.ChainID()
+	if err != nil {
+		return nil, err
+	}
+
+	return (*hexutil.Big)(chainId), nil
+}
+
+func (api *ethAPI) SignTransaction(ctx context.Context, args TransactionArgs) (*SignTransactionResult, error) {
+	signedTx, err := api.s.signTransaction(ctx, "eth_signTransaction", args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sent by the dapp
+	api.s.transactionDone(args, signedTx, nil)
+
+	raw, err := signedTx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignTransactionResult{raw, signedTx}, nil
+}
+
+// Signs and broadcasts the transaction without waiting for it to be mined
+func (api *ethAPI) SendTransaction(ctx context.Context, args TransactionArgs) (gethcommon.Hash, error) {
+	signedTx, err := api.s.signTransaction(ctx, "eth_sendTransaction", args)
+	if err != nil {
+		return gethcommon.Hash{}, err
+	}
+
+	err = api.s.client.EthClient.SendTransaction(ctx, signedTx)
+	api.s.transactionDone(args, signedTx, err)
+	if err != nil {
+		return gethcommon.Hash{}, err
+	}
+
+	return signedTx.Hash(), nil
+}
+
+// Typed data comes either as a JSON object or as a string holding one
+func (api *ethAPI) SignTypedData_v4(
+	ctx context.Context,
+	address gethcommon.Address,
+	typedData json.RawMessage,
+) (hexutil.Bytes, error) {
+	var typedDataString string
+	if err := json.Unmarshal(typedData, &typedDataString); err == nil {
+		typedData = json.RawMessage(typedDataString)
+	}
+
+	// Checked before bothering the user with an approval
+	if _, err := utils.TypedDataHash(typedData); err != nil {
+		return nil, wallet.InvalidTypedData
+	}
+
+	return api.s.signData(ctx, "eth_signTypedData_v4", address, typedData, func(autosign bool) ([]byte, error) {
+		return api.s.wk.SignTypedData(typedData, address, autosign)
+	})
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								PERSONAL NAMESPACE
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+type personalAPI struct {
+	s *Server
+}
+
+// personal_sign. The password argument is accepted for compatibility
+// but ignored, passphrases only come from the WalletUI.
+func (api *personalAPI) Sign(
+	ctx context.Context,
+	data hexutil.Bytes,
+	address gethcommon.Address,
+	password *string,
+) (hexutil.Bytes, error) {
+	return api.s.signData(ctx, "personal_sign", address, data, func(autosign bool) ([]byte, error) {
+		return api.s.wk.SignMessage(data, address, autosign)
+	})
+}
+
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//								SIGNING
+// ++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+func (s *Server) signTransaction(ctx context.Context, method string, args TransactionArgs) (*types.Transaction, error) {
+	if err := s.checkAccount(args.From); err != nil {
+		return nil, err
+	}
+
+	chainId, err := s.client.ChainID()
+	if err != nil {
+		return nil, err
+	}
+	if args.ChainID != nil && args.ChainID.ToInt().Cmp(chainId) != 0 {
+		return nil, ChainMismatch
+	}
+
+	tx, err := s.buildTransaction(args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.wk.Approve(&wallet.ApprovalRequest{
+		Method:       method,
+		Origin:       origin(ctx),
+		Account:      args.From,
+		ChainID:      chainId,
+		Transactions: []*types.Transaction{tx},
+	})
+	if err != nil {
+		s.transactionDone(args, tx, err)
+		return nil, toRPCError(err)
+	}
+
+	signedTx, err := s.wk.SignTransaction(chainId, tx, args.From, s.hasSession(args.From))
+	if err != nil {
+		s.transactionDone(args, tx, err)
+		return nil, toRPCError(err)
+	}
+
+	return signedTx, nil
+}
+
+func (s *Server) signData(
+	ctx context.Context,
+	method string,
+	address gethcommon.Address,
+	data []byte,
+	sign func(autosign bool) ([]byte, error),
+) (hexutil.Bytes, error) {
+	if err := s.checkAccount(address); err != nil {
+		return nil, err
+	}
+
+	err := s.wk.Approve(&wallet.ApprovalRequest{
+		Method:  method,
+		Origin:  origin(ctx),
+		Account: address,
+		Data:    data,
+	})
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+
+	signature, err := sign(s.hasSession(address))
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+
+	return signature, nil
+}
+
+// Nonce, gas and fees missing from args are filled in by the client,
+// with its nonce manager if it has one
+func (s *Server) buildTransaction(args TransactionArgs) (*types.Transaction, error) {
+	var data []byte
+	switch {
+	case args.Input != nil:
+		data = *args.Input
+	case args.Data != nil:
+		data = *args.Data
+	}
+
+	value := new(big.Int)
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+
+	if args.To == nil && len(data) == 0 {
+		return nil, InvalidTransaction
+	}
+
+	opts := client.TransactionOptions{
+		GasMultiplier: s.opts.GasMultiplier,
+		FeeStrategy:   s.opts.FeeStrategy,
+	}
+	if args.Nonce != nil {
+		nonce := uint64(*args.Nonce)
+		opts.Nonce = &nonce
+	}
+	if args.Gas != nil {
+		opts.Gas = uint64(*args.Gas)
+	}
+
+	switch {
+	case args.GasPrice != nil:
+		opts.Type = client.TransactionTypeLegacy
+		opts.FeeStrategy = &client.FixedFeeStrategy{GasFeeCap: args.GasPrice.ToInt()}
+	case args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil:
+		fees, err := s.dynamicFees(args)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.Type = client.TransactionTypeDynamicFee
+		opts.FeeStrategy = fees
+	}
+
+	msg := ethereum.CallMsg{
+		From:  args.From,
+		To:    args.To,
+		Value: value,
+		Data:  data,
+	}
+
+	tx, err := s.client.CreateTransactionWithOptions(msg, opts)
+	if err != nil {
+		return nil, toRPCError(err)
+	}
+
+	return tx, nil
+}
+
+// Fills in the fee a dapp left out when setting the other one
+func (s *Server) dynamicFees(args TransactionArgs) (*client.FixedFeeStrategy, error) {
+	fees := new(client.FixedFeeStrategy)
+
+	if args.MaxPriorityFeePerGas != nil {
+		fees.GasTipCap = args.MaxPriorityFeePerGas.ToInt()
+	} else {
+		tip, err := s.client.EthClient.SuggestGasTipCap(context.Background())
+		if err != nil {
+			return nil, &client.RPCError{Kind: client.GasTipFailed, Err: err}
+		}
+		fees.GasTipCap, _ = new(big.Float).Mul(new(big.Float).SetInt(tip), big.NewFloat(s.opts.GasMultiplier)).Int(nil)
+	}
+
+	if args.MaxFeePerGas != nil {
+		fees.GasFeeCap = args.MaxFeePerGas.ToInt()
+	} else {
+		gasPrice, err := s.client.EthClient.SuggestGasPrice(context.Background())
+		if err != nil {
+			return nil, &client.RPCError{Kind: client.GasPriceFailed, Err: err}
+		}
+		fees.GasFeeCap = gasPrice
+		if fees.GasFeeCap.Cmp(fees.GasTipCap) < 0 {
+			fees.GasFeeCap = fees.GasTipCap
+		}
+	}
+
+	return fees, nil
+}
+
+// Reports the result to the client's nonce manager,
+// unless the nonce was set by the dapp
+func (s *Server) transactionDone(args TransactionArgs, tx *types.Transaction, sendErr error) {
+	if args.Nonce != nil {
+		return
+	}
+
+	if err := s.client.TransactionDone(args.From, tx, sendErr); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (s *Server) checkAccount(address gethcommon.Address) error {
+	addresses, err := s.wk.Signer().Accounts()
+	if err != nil {
+		return err
+	}
+
+	for _, signerAddress := range addresses {
+		if signerAddress == address {
+			return nil
+		}
+	}
+
+	return &rpcError{errCodeUnauthorized, wallet.AccountNotFound}
+}
+
+func (s *Server) hasSession(address gethcommon.Address) bool {
+	_, ok := s.wk.Session(address)
+	return ok
+}
+
+func origin(ctx context.Context) string {
+	peer := rpc.PeerInfoFromContext(ctx)
+	if peer.HTTP.Origin != "" {
+		return peer.HTTP.Origin
+	}
+
+	return peer.RemoteAddr
+}
@@ -0,0 +1,148 @@
+package walletrpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/0xNSHuman/dapp-tools/client"
+	"github.com/0xNSHuman/dapp-tools/wallet"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const DefaultAddr = "127.0.0.1:1248"
+
+type Options struct {
+	// Listening address, must be a loopback one. Defaults to DefaultAddr.
+	Addr string
+
+	// Origins allowed to call the server from a browser, "*" allows any.
+	// Requests without an Origin header, e.g. from scripts, are always allowed.
+	AllowedOrigins []string
+
+	// Applied to the suggested tip of transactions without fees set
+	GasMultiplier float64
+
+	// Fees of transactions without fees set, nil means the client's default
+	FeeStrategy client.FeeStrategy
+}
+
+// Localhost JSON-RPC server exposing the wallet to dapps and tools.
+// Every signing request has to be approved through the wallet's WalletUI.
+// Accounts with an unlock session sign without asking for the passphrase.
+type Server struct {
+	wk     *wallet.WalletKeeper
+	client *client.Client
+	opts   Options
+
+	rpc  *rpc.Server
+	http *http.Server
+}
+
+func NewServer(wk *wallet.WalletKeeper, client *client.Client, opts Options) (*Server, error) {
+	if opts.Addr == "" {
+		opts.Addr = DefaultAddr
+	}
+	if opts.GasMultiplier == 0 {
+		opts.GasMultiplier = 1
+	}
+
+	if !isLoopback(opts.Addr) {
+		return nil, NonLocalAddress
+	}
+
+	s := &Server{
+		wk:     wk,
+		client: client,
+		opts:   opts,
+		rpc:    rpc.NewServer(),
+	}
+
+	if err := s.rpc.RegisterName("eth", &ethAPI{s}); err != nil {
+		return nil, err
+	}
+	if err := s.rpc.RegisterName("personal", &personalAPI{s}); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Blocks until the server is closed
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+
+	s.http = &http.Server{Handler: s}
+
+	err = s.http.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
+
+func (s *Server) Close() error {
+	s.rpc.Stop()
+
+	if s.http == nil {
+		return nil
+	}
+
+	return s.http.Shutdown(context.Background())
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Guards against DNS rebinding, the host has to be local as well
+	if !isLoopback(r.Host) {
+		http.Error(w, "invalid host", http.StatusForbidden)
+		return
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !s.originAllowed(origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Vary", "Origin")
+	}
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.rpc.ServeHTTP(w, r)
+}
+
+func (s *Server) originAllowed(origin string) bool {
+	for _, allowed := range s.opts.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isLoopback(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
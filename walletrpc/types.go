@@ -0,0 +1,27 @@
+package walletrpc
+
+import (
+	"github.com/0xNSHuman/dapp-tools/common"
+)
+
+type ServerError uint
+
+const (
+	Unknown ServerError = common.ErrorDomainWalletRPC + iota
+	NonLocalAddress
+	InvalidTransaction
+	ChainMismatch
+)
+
+func (e ServerError) Error() string {
+	switch e {
+	case NonLocalAddress:
+		return "Server address must be a loopback one"
+	case InvalidTransaction:
+		return "Invalid transaction"
+	case ChainMismatch:
+		return "Chain ID doesn't match the node"
+	default:
+		return "Unknown"
+	}
+}